@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+// identityKey is the context key holding the authenticated identity
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the authenticated identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated identity, if any
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Identity is the project a write key belongs to
+type Identity struct {
+	// KeyID is a short, non-secret fingerprint of the write key suitable for logs
+	KeyID     string `json:"key_id"`
+	Name      string `json:"name,omitempty"`
+	ProjectID string `json:"project_id"`
+	TenantID  string `json:"tenant_id,omitempty"`
+
+	// AllowedOrigins restricts browser requests made with this key; empty allows any origin
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// RateLimit is the request quota per rate limit window; 0 uses the default key quota
+	RateLimit int `json:"rate_limit,omitempty"`
+}
+
+// KeyEntry is a write key as stored in the key store file. Either the plain
+// key or its hex-encoded SHA-256 hash must be set; hashes keep the file free of secrets.
+type KeyEntry struct {
+	Key       string `json:"key,omitempty"`
+	KeyHash   string `json:"key_sha256,omitempty"`
+	Name      string `json:"name,omitempty"`
+	ProjectID string `json:"project_id"`
+	TenantID  string `json:"tenant_id,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	RateLimit      int      `json:"rate_limit,omitempty"`
+}
+
+// keyFile is the layout of the key store file
+type keyFile struct {
+	Keys []KeyEntry `json:"keys"`
+}
+
+// KeyStore resolves write keys to identities from a local JSON file and
+// reloads the file when it changes on disk.
+type KeyStore struct {
+	path    string
+	logger  *zap.Logger
+	mu      sync.RWMutex
+	keys    map[string]Identity
+	modTime time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewKeyStore loads the key store file and, when reloadInterval is positive,
+// watches it for changes
+func NewKeyStore(path string, reloadInterval time.Duration, logger *zap.Logger) (*KeyStore, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &KeyStore{
+		path:   path,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if err := store.Reload(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if reloadInterval > 0 {
+		go store.watch(reloadInterval)
+	}
+
+	return store, nil
+}
+
+// Lookup resolves a write key to its identity
+func (s *KeyStore) Lookup(key string) (Identity, bool) {
+	if key == "" {
+		return Identity{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.keys[hashKey(key)]
+	return identity, ok
+}
+
+// Len returns the number of active keys
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Reload reads the key store file and replaces the active keys
+func (s *KeyStore) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat key store: %w", err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key store: %w", err)
+	}
+
+	keys := make(map[string]Identity, len(file.Keys))
+	for i, entry := range file.Keys {
+		hash, err := entry.hash()
+		if err != nil {
+			return fmt.Errorf("invalid key store entry %d: %w", i, err)
+		}
+		if entry.ProjectID == "" {
+			return fmt.Errorf("invalid key store entry %d: project_id is required", i)
+		}
+		if entry.RateLimit < 0 {
+			return fmt.Errorf("invalid key store entry %d: rate_limit must be non-negative", i)
+		}
+		if _, exists := keys[hash]; exists {
+			return fmt.Errorf("invalid key store entry %d: duplicate key", i)
+		}
+		if entry.Disabled {
+			continue
+		}
+
+		keys[hash] = Identity{
+			KeyID:     hash[:12],
+			Name:      entry.Name,
+			ProjectID: entry.ProjectID,
+			TenantID:  entry.TenantID,
+
+			AllowedOrigins: entry.AllowedOrigins,
+			RateLimit:      entry.RateLimit,
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Close stops watching the key store file
+func (s *KeyStore) Close() {
+	s.cancel()
+}
+
+// watch reloads the file whenever its modification time changes. A file that
+// fails to parse is logged and the previous keys stay active.
+func (s *KeyStore) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				s.logger.Warn("Failed to stat key store", zap.String("path", s.path), zap.Error(err))
+				continue
+			}
+
+			s.mu.RLock()
+			unchanged := info.ModTime().Equal(s.modTime)
+			s.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+
+			if err := s.Reload(); err != nil {
+				s.logger.Error("Failed to reload key store, keeping previous keys",
+					zap.String("path", s.path),
+					zap.Error(err),
+				)
+				continue
+			}
+			s.logger.Info("Key store reloaded", zap.String("path", s.path), zap.Int("keys", s.Len()))
+		}
+	}
+}
+
+// hash returns the normalised SHA-256 hash of the entry's key
+func (e KeyEntry) hash() (string, error) {
+	switch {
+	case e.Key != "" && e.KeyHash != "":
+		return "", fmt.Errorf("set either key or key_sha256, not both")
+	case e.Key != "":
+		return hashKey(e.Key), nil
+	case e.KeyHash != "":
+		decoded, err := hex.DecodeString(e.KeyHash)
+		if err != nil || len(decoded) != sha256.Size {
+			return "", fmt.Errorf("key_sha256 must be a hex-encoded SHA-256 hash")
+		}
+		return strings.ToLower(e.KeyHash), nil
+	default:
+		return "", fmt.Errorf("key or key_sha256 is required")
+	}
+}
+
+// hashKey returns the hex-encoded SHA-256 hash of a write key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
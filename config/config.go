@@ -2,33 +2,291 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// sinkNamePattern restricts sink names to characters valid in environment variable names
+var sinkNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 // Config holds application configuration
 type Config struct {
-	Server ServerConfig
-	Kafka  KafkaConfig
+	Server     ServerConfig
+	Sinks      []SinkConfig
+	Kafka      KafkaConfig
+	NATS       NATSConfig
+	Redis      RedisConfig
+	Webhook    WebhookConfig
+	Monitor    MonitorConfig
+	Schema     SchemaConfig
+	Auth       AuthConfig
+	CORS       CORSConfig
+	Security   SecurityConfig
+	Dedup      DedupConfig
+	Enrichment EnrichmentConfig
+	Privacy    PrivacyConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
 	Port string
 	Host string
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For headers are honoured
+	TrustedProxies []string
+	// ConfirmDelivery makes every request wait until the required sinks have stored the event
+	ConfirmDelivery bool
+}
+
+// SinkConfig configures one event sink, from SINK_<NAME>_* variables
+type SinkConfig struct {
+	Name string
+	// Type is kafka, nats, redis, memory, file or webhook
+	Type string
+	// Policy is required or best_effort. Events are acknowledged once every required sink
+	// selecting them has accepted them; best-effort failures are only logged.
+	Policy string
+
+	// EventTypes and Tenants select the events the sink receives; empty lists select all
+	EventTypes []string
+	Tenants    []string
+
+	// Kafka holds the KAFKA_* settings with this sink's overrides, e.g. for a secondary cluster
+	Kafka KafkaConfig
+
+	// MemoryMaxEvents bounds the memory sink; the oldest events are discarded beyond it
+	MemoryMaxEvents int
+
+	// File sink settings
+	FileDir           string
+	FileMaxBytes      int64
+	FileMaxAge        time.Duration
+	FileGzip          bool
+	FileFlushInterval time.Duration
+	FileMaxOpenFiles  int
 }
 
 // KafkaConfig holds Kafka-related configuration
 type KafkaConfig struct {
 	Brokers         []string
 	Topic           string
+	TopicRoutes     []TopicRoute
+	PartitionKey    string
 	Acks            string
 	Retries         int
 	BatchSize       int
 	LingerMs        int
 	Compression     string
 	MaxMessageBytes int
+	DeliveryTimeout time.Duration
+
+	// Disk spool for events Kafka could not deliver; disabled when SpoolDir is empty
+	SpoolDir            string
+	SpoolMaxBytes       int64
+	SpoolReplayInterval time.Duration
+
+	// DeadLetterTopic receives invalid and undeliverable events; disabled when empty
+	DeadLetterTopic string
+}
+
+// NATSConfig holds NATS JetStream sink configuration
+type NATSConfig struct {
+	URL           string
+	Subject       string
+	SubjectRoutes []TopicRoute
+
+	// Stream captures the subjects; with CreateStream it is created or updated on start
+	Stream       string
+	CreateStream bool
+	Replicas     int
+	// Compression is none or s2
+	Compression string
+
+	MaxPending      int
+	DeliveryTimeout time.Duration
+
+	// DeadLetterSubject receives invalid and undeliverable events; disabled when empty
+	DeadLetterSubject string
+}
+
+// RedisConfig holds Redis Streams sink configuration
+type RedisConfig struct {
+	URL          string
+	Stream       string
+	StreamRoutes []TopicRoute
+
+	// MaxLen trims each stream to about this many entries; 0 keeps every entry
+	MaxLen int64
+	// MinReplicas is how many replicas must acknowledge each write
+	MinReplicas int
+	// Retries re-sends a failed pipeline; retried entries may be appended twice
+	Retries int
+
+	BatchSize       int
+	LingerMs        int
+	QueueSize       int
+	MaxMessageBytes int
+	DeliveryTimeout time.Duration
+
+	// DeadLetterStream receives invalid and undeliverable events; disabled when empty
+	DeadLetterStream string
+}
+
+// WebhookConfig holds the outbound webhook sink configuration
+type WebhookConfig struct {
+	Endpoints []WebhookEndpoint
+
+	Timeout        time.Duration
+	MaxRetries     int
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// Concurrency limits requests in flight across all endpoints and batches outstanding per endpoint
+	Concurrency int
+	// BatchSize events are sent per request, or fewer after BatchInterval
+	BatchSize     int
+	BatchInterval time.Duration
+	QueueSize     int
+
+	// BreakerThreshold consecutive failures open an endpoint's circuit for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// WebhookEndpoint is a webhook destination, from WEBHOOK_<NAME>_* variables
+type WebhookEndpoint struct {
+	Name       string
+	URL        string
+	Secret     string
+	EventTypes []string
+}
+
+// MonitorConfig holds observability configuration
+type MonitorConfig struct {
+	EnableMetrics   bool
+	MetricsPort     string
+	MetricsEndpoint string
+
+	// Readiness fails when the delivery error rate over ReadinessErrorWindow exceeds
+	// ReadinessMaxErrorRate, once at least ReadinessMinSamples deliveries were observed
+	ReadinessErrorWindow  time.Duration
+	ReadinessMaxErrorRate float64
+	ReadinessMinSamples   int
+	ReadinessTimeout      time.Duration
+}
+
+// SchemaConfig holds event schema validation configuration
+type SchemaConfig struct {
+	// Dir holds JSON Schema files named <event_type>.json or <event_type>/<version>.json
+	Dir string
+	// UnknownEventPolicy is "allow" or "reject" for event types without a schema
+	UnknownEventPolicy string
+}
+
+// AuthConfig holds write key authentication configuration
+type AuthConfig struct {
+	Enabled bool
+	// KeysFile is the JSON key store mapping write keys to projects
+	KeysFile string
+	// ReloadInterval is how often the key store file is checked for changes; 0 disables reloading
+	ReloadInterval time.Duration
+}
+
+// CORSConfig holds Cross-Origin Resource Sharing configuration
+type CORSConfig struct {
+	// AllowedOrigins holds exact origins, wildcard subdomains such as https://*.example.com, or *
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, browsers may cache a preflight response
+	MaxAge int
+}
+
+// SecurityConfig holds abuse protection configuration
+type SecurityConfig struct {
+	// EnableRateLimiting applies token buckets per client IP, user_id and write key
+	EnableRateLimiting bool
+	// RateLimitRequests is the budget per RateLimitWindow for each client IP and user_id
+	RateLimitRequests int
+	// RateLimitKeyRequests is the default budget per RateLimitWindow for each write key
+	RateLimitKeyRequests int
+	RateLimitWindow      time.Duration
+}
+
+// DedupConfig holds idempotency configuration for events carrying an event_id or Idempotency-Key
+type DedupConfig struct {
+	Enabled bool
+	// Window is how long an idempotency key is remembered
+	Window time.Duration
+	// MaxEntries bounds the cache; the oldest keys are evicted early when it is full
+	MaxEntries int
+}
+
+// EnrichmentConfig holds server-side event enrichment configuration
+type EnrichmentConfig struct {
+	// UserAgent adds the user_agent step to the default pipeline
+	UserAgent          bool
+	UserAgentCacheSize int
+
+	// GeoIP databases in MaxMind format; lookups are disabled when both are empty
+	GeoIPCityDB         string
+	GeoIPASNDB          string
+	GeoIPReloadInterval time.Duration
+
+	// Steps is the ordered enrichment pipeline run on every event
+	Steps []EnrichmentStep
+}
+
+// EnrichmentStep configures one enrichment pipeline step
+type EnrichmentStep struct {
+	Name    string
+	Timeout time.Duration
+	// OnError is fail, skip or tag
+	OnError string
+}
+
+// PrivacyConfig holds the privacy rules applied before events are published
+type PrivacyConfig struct {
+	// TruncateIP zeroes client IPs beyond IPv4Prefix/IPv6Prefix bits
+	TruncateIP bool
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// DropKeys and HashKeys name event_data keys to remove or replace with a salted hash
+	DropKeys []string
+	HashKeys []string
+	HashSalt string
+
+	// Scrub lists the detectors applied to event_data strings and page_url query values
+	Scrub []string
+}
+
+// TopicRoute maps an event type pattern to a destination topic
+type TopicRoute struct {
+	Pattern string
+	Topic   string
+}
+
+// validatePattern checks that a re: pattern is a valid regular expression and a glob a valid glob
+func (r TopicRoute) validatePattern() error {
+	switch {
+	case strings.HasPrefix(r.Pattern, "re:"):
+		if _, err := regexp.Compile(strings.TrimPrefix(r.Pattern, "re:")); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", r.Pattern, err)
+		}
+	case strings.ContainsAny(r.Pattern, "*?["):
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", r.Pattern, err)
+		}
+	}
+	return nil
 }
 
 // LoadConfig loads configuration from environment variables
@@ -37,19 +295,137 @@ func LoadConfig() (*Config, error) {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "9094"),
 			Host: getEnv("HOST", "0.0.0.0"),
+
+			TrustedProxies: parseList(getEnv("SERVER_TRUSTED_PROXIES", "")),
+			// KAFKA_CONFIRM_DELIVERY is the deprecated name from before sinks other than Kafka
+			ConfirmDelivery: getEnvAsBool("CONFIRM_DELIVERY", getEnvAsBool("KAFKA_CONFIRM_DELIVERY", false)),
 		},
 		Kafka: KafkaConfig{
 			Brokers:         parseBrokers(getEnv("KAFKA_BROKERS", "localhost:9092")),
 			Topic:           getEnv("KAFKA_TOPIC", "user-activity-events"),
+			TopicRoutes:     parseTopicRoutes(getEnv("KAFKA_TOPIC_ROUTES", "")),
+			PartitionKey:    getEnv("KAFKA_PARTITION_KEY", "event_id"),
 			Acks:            getEnv("KAFKA_ACKS", "all"),
 			Retries:         getEnvAsInt("KAFKA_RETRIES", 3),
 			BatchSize:       getEnvAsInt("KAFKA_BATCH_SIZE", 16384),
 			LingerMs:        getEnvAsInt("KAFKA_LINGER_MS", 5),
 			Compression:     getEnv("KAFKA_COMPRESSION", "snappy"),
 			MaxMessageBytes: getEnvAsInt("KAFKA_MAX_MESSAGE_BYTES", 1000000),
+			DeliveryTimeout: getEnvAsDuration("KAFKA_DELIVERY_TIMEOUT", 10*time.Second),
+
+			SpoolDir:            getEnv("KAFKA_SPOOL_DIR", ""),
+			SpoolMaxBytes:       int64(getEnvAsInt("KAFKA_SPOOL_MAX_BYTES", 1024*1024*1024)),
+			SpoolReplayInterval: getEnvAsDuration("KAFKA_SPOOL_REPLAY_INTERVAL", 30*time.Second),
+
+			DeadLetterTopic: getEnv("KAFKA_DEAD_LETTER_TOPIC", ""),
+		},
+		NATS: NATSConfig{
+			URL:           getEnv("NATS_URL", "nats://localhost:4222"),
+			Subject:       getEnv("NATS_SUBJECT", "user-activity-events"),
+			SubjectRoutes: parseTopicRoutes(getEnv("NATS_SUBJECT_ROUTES", "")),
+
+			Stream:       getEnv("NATS_STREAM", "USER_ACTIVITY"),
+			CreateStream: getEnvAsBool("NATS_CREATE_STREAM", true),
+			Replicas:     getEnvAsInt("NATS_REPLICAS", 1),
+			Compression:  getEnv("NATS_COMPRESSION", "s2"),
+
+			MaxPending:      getEnvAsInt("NATS_MAX_PENDING", 4096),
+			DeliveryTimeout: getEnvAsDuration("NATS_DELIVERY_TIMEOUT", 10*time.Second),
+
+			DeadLetterSubject: getEnv("NATS_DEAD_LETTER_SUBJECT", ""),
+		},
+		Redis: RedisConfig{
+			URL:          getEnv("REDIS_URL", "redis://localhost:6379/0"),
+			Stream:       getEnv("REDIS_STREAM", "user-activity-events"),
+			StreamRoutes: parseTopicRoutes(getEnv("REDIS_STREAM_ROUTES", "")),
+
+			MaxLen:      int64(getEnvAsInt("REDIS_MAX_LEN", 0)),
+			MinReplicas: getEnvAsInt("REDIS_MIN_REPLICAS", 0),
+			Retries:     getEnvAsInt("REDIS_RETRIES", 0),
+
+			BatchSize:       getEnvAsInt("REDIS_BATCH_SIZE", 100),
+			LingerMs:        getEnvAsInt("REDIS_LINGER_MS", 5),
+			QueueSize:       getEnvAsInt("REDIS_QUEUE_SIZE", 10000),
+			MaxMessageBytes: getEnvAsInt("REDIS_MAX_MESSAGE_BYTES", 1000000),
+			DeliveryTimeout: getEnvAsDuration("REDIS_DELIVERY_TIMEOUT", 10*time.Second),
+
+			DeadLetterStream: getEnv("REDIS_DEAD_LETTER_STREAM", ""),
+		},
+		Webhook: WebhookConfig{
+			Endpoints: loadWebhookEndpoints(),
+
+			Timeout:        getEnvAsDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+			MaxRetries:     getEnvAsInt("WEBHOOK_MAX_RETRIES", 5),
+			BackoffInitial: getEnvAsDuration("WEBHOOK_BACKOFF_INITIAL", 500*time.Millisecond),
+			BackoffMax:     getEnvAsDuration("WEBHOOK_BACKOFF_MAX", 30*time.Second),
+
+			Concurrency:   getEnvAsInt("WEBHOOK_CONCURRENCY", 8),
+			BatchSize:     getEnvAsInt("WEBHOOK_BATCH_SIZE", 1),
+			BatchInterval: getEnvAsDuration("WEBHOOK_BATCH_INTERVAL", time.Second),
+			QueueSize:     getEnvAsInt("WEBHOOK_QUEUE_SIZE", 10000),
+
+			BreakerThreshold: getEnvAsInt("WEBHOOK_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getEnvAsDuration("WEBHOOK_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		Monitor: MonitorConfig{
+			EnableMetrics:   getEnvAsBool("MONITOR_ENABLE_METRICS", true),
+			MetricsPort:     getEnv("MONITOR_METRICS_PORT", ""),
+			MetricsEndpoint: getEnv("MONITOR_METRICS_ENDPOINT", "/metrics"),
+
+			ReadinessErrorWindow:  getEnvAsDuration("MONITOR_READINESS_ERROR_WINDOW", time.Minute),
+			ReadinessMaxErrorRate: getEnvAsFloat("MONITOR_READINESS_MAX_ERROR_RATE", 0.5),
+			ReadinessMinSamples:   getEnvAsInt("MONITOR_READINESS_MIN_SAMPLES", 20),
+			ReadinessTimeout:      getEnvAsDuration("MONITOR_READINESS_TIMEOUT", 3*time.Second),
+		},
+		Schema: SchemaConfig{
+			Dir:                getEnv("SCHEMA_DIR", ""),
+			UnknownEventPolicy: getEnv("SCHEMA_UNKNOWN_EVENT_POLICY", "allow"),
+		},
+		Auth: AuthConfig{
+			Enabled:        getEnvAsBool("AUTH_ENABLED", false),
+			KeysFile:       getEnv("AUTH_KEYS_FILE", ""),
+			ReloadInterval: getEnvAsDuration("AUTH_RELOAD_INTERVAL", 30*time.Second),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   parseList(getEnv("CORS_ALLOWED_ORIGINS", "*")),
+			AllowedMethods:   parseList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,OPTIONS")),
+			AllowedHeaders:   parseList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-Requested-With,X-Delivery-Confirmation,X-Tenant-ID,X-Write-Key,Idempotency-Key,traceparent,tracestate")),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvAsInt("CORS_MAX_AGE", 86400),
+		},
+		Security: SecurityConfig{
+			EnableRateLimiting:   getEnvAsBool("SECURITY_ENABLE_RATE_LIMITING", false),
+			RateLimitRequests:    getEnvAsInt("SECURITY_RATE_LIMIT_REQUESTS", 100),
+			RateLimitKeyRequests: getEnvAsInt("SECURITY_RATE_LIMIT_KEY_REQUESTS", 10000),
+			RateLimitWindow:      getEnvAsDuration("SECURITY_RATE_LIMIT_WINDOW", time.Minute),
+		},
+		Dedup: DedupConfig{
+			Enabled:    getEnvAsBool("DEDUP_ENABLED", true),
+			Window:     getEnvAsDuration("DEDUP_WINDOW", time.Hour),
+			MaxEntries: getEnvAsInt("DEDUP_MAX_ENTRIES", 500000),
+		},
+		Enrichment: EnrichmentConfig{
+			UserAgent:          getEnvAsBool("ENRICHMENT_USER_AGENT", true),
+			UserAgentCacheSize: getEnvAsInt("ENRICHMENT_USER_AGENT_CACHE_SIZE", 10000),
+
+			GeoIPCityDB:         getEnv("ENRICHMENT_GEOIP_CITY_DB", ""),
+			GeoIPASNDB:          getEnv("ENRICHMENT_GEOIP_ASN_DB", ""),
+			GeoIPReloadInterval: getEnvAsDuration("ENRICHMENT_GEOIP_RELOAD_INTERVAL", time.Minute),
+		},
+		Privacy: PrivacyConfig{
+			TruncateIP: getEnvAsBool("PRIVACY_TRUNCATE_IP", false),
+			IPv4Prefix: getEnvAsInt("PRIVACY_IPV4_PREFIX", 24),
+			IPv6Prefix: getEnvAsInt("PRIVACY_IPV6_PREFIX", 48),
+			DropKeys:   parseList(getEnv("PRIVACY_DROP_KEYS", "")),
+			HashKeys:   parseList(getEnv("PRIVACY_HASH_KEYS", "")),
+			HashSalt:   getEnv("PRIVACY_HASH_SALT", ""),
+			Scrub:      parseList(getEnv("PRIVACY_SCRUB", "")),
 		},
 	}
 
+	config.Enrichment.Steps = loadEnrichmentSteps(config.Enrichment, config.Privacy)
+	config.Sinks = loadSinks(config.Kafka)
+
 	if err := config.validate(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
@@ -62,45 +438,505 @@ func (c *Config) GetServerAddress() string {
 	return c.Server.Host + ":" + c.Server.Port
 }
 
+// SeparateMetricsServer reports whether metrics are served on their own port
+func (c *Config) SeparateMetricsServer() bool {
+	return c.Monitor.EnableMetrics && c.Monitor.MetricsPort != "" && c.Monitor.MetricsPort != c.Server.Port
+}
+
+// GetMetricsAddress returns the address of the dedicated metrics server
+func (c *Config) GetMetricsAddress() string {
+	return c.Server.Host + ":" + c.Monitor.MetricsPort
+}
+
 // validate performs configuration validation
 func (c *Config) validate() error {
-	if len(c.Kafka.Brokers) == 0 {
+	if err := c.validateSinks(); err != nil {
+		return err
+	}
+
+	if c.Monitor.EnableMetrics && !strings.HasPrefix(c.Monitor.MetricsEndpoint, "/") {
+		return fmt.Errorf("metrics endpoint must start with /")
+	}
+
+	if c.Monitor.ReadinessErrorWindow < time.Second {
+		return fmt.Errorf("readiness error window must be at least 1s")
+	}
+
+	if c.Monitor.ReadinessMaxErrorRate < 0 || c.Monitor.ReadinessMaxErrorRate > 1 {
+		return fmt.Errorf("readiness max error rate must be between 0 and 1")
+	}
+
+	if c.Schema.UnknownEventPolicy != "allow" && c.Schema.UnknownEventPolicy != "reject" {
+		return fmt.Errorf("invalid schema unknown event policy: %s", c.Schema.UnknownEventPolicy)
+	}
+
+	if c.Auth.Enabled && c.Auth.KeysFile == "" {
+		return fmt.Errorf("auth keys file must be specified when auth is enabled")
+	}
+
+	if c.Auth.ReloadInterval < 0 {
+		return fmt.Errorf("auth reload interval must be non-negative")
+	}
+
+	if c.CORS.AllowCredentials {
+		for _, origin := range c.CORS.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("CORS allowed origins cannot be * when credentials are allowed")
+			}
+		}
+	}
+
+	if c.CORS.MaxAge < 0 {
+		return fmt.Errorf("CORS max age must be non-negative")
+	}
+
+	if c.Security.EnableRateLimiting {
+		if c.Security.RateLimitRequests <= 0 || c.Security.RateLimitKeyRequests <= 0 {
+			return fmt.Errorf("rate limit requests must be positive")
+		}
+
+		if c.Security.RateLimitWindow <= 0 {
+			return fmt.Errorf("rate limit window must be positive")
+		}
+	}
+
+	if c.Dedup.Enabled && (c.Dedup.Window <= 0 || c.Dedup.MaxEntries <= 0) {
+		return fmt.Errorf("dedup window and max entries must be positive")
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+	}
+
+	if c.Enrichment.UserAgentCacheSize <= 0 {
+		return fmt.Errorf("user agent cache size must be positive")
+	}
+
+	if len(c.Privacy.HashKeys) > 0 && c.Privacy.HashSalt == "" {
+		return fmt.Errorf("privacy hash salt must be specified when hashing keys")
+	}
+
+	if err := c.validateEnrichmentSteps(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateSinks checks the sink list and each sink's settings
+func (c *Config) validateSinks() error {
+	if len(c.Sinks) == 0 {
+		return fmt.Errorf("at least one sink must be specified")
+	}
+
+	names := make(map[string]bool, len(c.Sinks))
+	spoolDirs := make(map[string]string)
+	fileDirs := make(map[string]string)
+	required := false
+	singletons := make(map[string]bool)
+	sharedSinkValidators := map[string]func() error{
+		"nats":    c.NATS.validate,
+		"redis":   c.Redis.validate,
+		"webhook": c.Webhook.validate,
+	}
+
+	for _, sink := range c.Sinks {
+		if !sinkNamePattern.MatchString(sink.Name) {
+			return fmt.Errorf("invalid sink name %q: use letters, digits and underscores", sink.Name)
+		}
+		if names[strings.ToUpper(sink.Name)] {
+			return fmt.Errorf("sink %s is listed more than once", sink.Name)
+		}
+		names[strings.ToUpper(sink.Name)] = true
+
+		switch sink.Policy {
+		case "required":
+			required = true
+		case "best_effort":
+		default:
+			return fmt.Errorf("invalid policy for sink %s: %s", sink.Name, sink.Policy)
+		}
+
+		switch sink.Type {
+		case "kafka":
+			// KAFKA_* settings are only checked when a sink uses them
+			if err := sink.Kafka.validate(); err != nil {
+				return fmt.Errorf("invalid settings for Kafka sink %s: %w", sink.Name, err)
+			}
+			if dir := sink.Kafka.SpoolDir; dir != "" {
+				if other, ok := spoolDirs[dir]; ok {
+					return fmt.Errorf("sinks %s and %s share the spool directory %s", other, sink.Name, dir)
+				}
+				spoolDirs[dir] = sink.Name
+			}
+		case "nats", "redis", "webhook":
+			// These sinks are configured by NATS_*, REDIS_* and WEBHOOK_* rather than per sink
+			if singletons[sink.Type] {
+				return fmt.Errorf("only one %s sink may be configured", sink.Type)
+			}
+			singletons[sink.Type] = true
+			if err := sharedSinkValidators[sink.Type](); err != nil {
+				return err
+			}
+		case "memory":
+			if sink.MemoryMaxEvents <= 0 {
+				return fmt.Errorf("max events of memory sink %s must be positive", sink.Name)
+			}
+		case "file":
+			if sink.FileDir == "" {
+				return fmt.Errorf("file sink %s directory must be specified", sink.Name)
+			}
+			if sink.FileMaxBytes <= 0 || sink.FileMaxAge <= 0 || sink.FileFlushInterval <= 0 || sink.FileMaxOpenFiles <= 0 {
+				return fmt.Errorf("file sink %s max bytes, max age, flush interval and max open files must be positive", sink.Name)
+			}
+			// Each sink completes the other's open files as leftovers of a crash
+			dir := filepath.Clean(sink.FileDir)
+			if other, ok := fileDirs[dir]; ok {
+				return fmt.Errorf("file sinks %s and %s share the directory %s", other, sink.Name, dir)
+			}
+			fileDirs[dir] = sink.Name
+		default:
+			return fmt.Errorf("invalid type for sink %s: %s", sink.Name, sink.Type)
+		}
+	}
+
+	if !required {
+		return fmt.Errorf("at least one sink must be required")
+	}
+
+	return nil
+}
+
+// validate checks the brokers, topics, producer and spool settings of a Kafka sink
+func (k KafkaConfig) validate() error {
+	if len(k.Brokers) == 0 {
 		return fmt.Errorf("at least one Kafka broker must be specified")
 	}
 
-	if c.Kafka.Topic == "" {
+	if k.Topic == "" {
 		return fmt.Errorf("Kafka topic must be specified")
 	}
 
+	for _, route := range k.TopicRoutes {
+		if route.Pattern == "" || route.Topic == "" {
+			return fmt.Errorf("invalid Kafka topic route: expected pattern=topic")
+		}
+		if err := route.validatePattern(); err != nil {
+			return fmt.Errorf("invalid Kafka topic route: %w", err)
+		}
+	}
+
 	validAcks := map[string]bool{"all": true, "1": true, "0": true}
-	if !validAcks[c.Kafka.Acks] {
-		return fmt.Errorf("invalid Kafka acks value: %s", c.Kafka.Acks)
+	if !validAcks[k.Acks] {
+		return fmt.Errorf("invalid Kafka acks value: %s", k.Acks)
 	}
 
-	if c.Kafka.Retries < 0 {
+	if k.Retries < 0 {
 		return fmt.Errorf("Kafka retries must be non-negative")
 	}
 
-	if c.Kafka.BatchSize <= 0 {
+	if k.BatchSize <= 0 {
 		return fmt.Errorf("Kafka batch size must be positive")
 	}
 
-	if c.Kafka.LingerMs < 0 {
+	if k.LingerMs < 0 {
 		return fmt.Errorf("Kafka linger ms must be non-negative")
 	}
 
 	validCompression := map[string]bool{"none": true, "gzip": true, "snappy": true, "lz4": true, "zstd": true}
-	if !validCompression[c.Kafka.Compression] {
-		return fmt.Errorf("invalid Kafka compression: %s", c.Kafka.Compression)
+	if !validCompression[k.Compression] {
+		return fmt.Errorf("invalid Kafka compression: %s", k.Compression)
 	}
 
-	if c.Kafka.MaxMessageBytes <= 0 {
+	if k.MaxMessageBytes <= 0 {
 		return fmt.Errorf("Kafka max message bytes must be positive")
 	}
 
+	if k.DeliveryTimeout <= 0 {
+		return fmt.Errorf("Kafka delivery timeout must be positive")
+	}
+
+	if k.DeadLetterTopic != "" && k.DeadLetterTopic == k.Topic {
+		return fmt.Errorf("Kafka dead-letter topic must differ from the main topic")
+	}
+
+	if k.SpoolDir != "" {
+		if k.SpoolMaxBytes <= 0 {
+			return fmt.Errorf("Kafka spool max bytes must be positive")
+		}
+
+		if k.SpoolReplayInterval <= 0 {
+			return fmt.Errorf("Kafka spool replay interval must be positive")
+		}
+	}
+
+	return nil
+}
+
+// natsTokenPattern matches NATS subjects and stream names without wildcards or whitespace
+var natsTokenPattern = regexp.MustCompile(`^[^\s*>]+$`)
+
+// validate checks the NATS subjects, stream and publisher settings
+func (n NATSConfig) validate() error {
+	if n.URL == "" {
+		return fmt.Errorf("NATS URL must be specified")
+	}
+
+	if n.Stream == "" || strings.Contains(n.Stream, ".") || !natsTokenPattern.MatchString(n.Stream) {
+		return fmt.Errorf("invalid NATS stream name: %q", n.Stream)
+	}
+
+	subjects := []string{n.Subject}
+	for _, route := range n.SubjectRoutes {
+		if route.Pattern == "" || route.Topic == "" {
+			return fmt.Errorf("invalid NATS subject route: expected pattern=subject")
+		}
+		if err := route.validatePattern(); err != nil {
+			return fmt.Errorf("invalid NATS subject route: %w", err)
+		}
+		subjects = append(subjects, route.Topic)
+	}
+	if n.DeadLetterSubject != "" {
+		if n.DeadLetterSubject == n.Subject {
+			return fmt.Errorf("NATS dead-letter subject must differ from the main subject")
+		}
+		subjects = append(subjects, n.DeadLetterSubject)
+	}
+	for _, subject := range subjects {
+		if !natsTokenPattern.MatchString(subject) {
+			return fmt.Errorf("invalid NATS subject: %q", subject)
+		}
+	}
+
+	if n.Replicas < 1 || n.Replicas > 5 {
+		return fmt.Errorf("NATS replicas must be between 1 and 5")
+	}
+
+	if n.Compression != "none" && n.Compression != "s2" {
+		return fmt.Errorf("invalid NATS compression: %s", n.Compression)
+	}
+
+	if n.MaxPending <= 0 || n.DeliveryTimeout <= 0 {
+		return fmt.Errorf("NATS max pending and delivery timeout must be positive")
+	}
+
+	return nil
+}
+
+// validate checks the Redis streams and writer settings
+func (r RedisConfig) validate() error {
+	parsed, err := url.Parse(r.URL)
+	if err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss" && parsed.Scheme != "unix") {
+		return fmt.Errorf("Redis URL must use the redis, rediss or unix scheme")
+	}
+
+	if r.Stream == "" {
+		return fmt.Errorf("Redis stream must be specified")
+	}
+
+	for _, route := range r.StreamRoutes {
+		if route.Pattern == "" || route.Topic == "" {
+			return fmt.Errorf("invalid Redis stream route: expected pattern=stream")
+		}
+		if err := route.validatePattern(); err != nil {
+			return fmt.Errorf("invalid Redis stream route: %w", err)
+		}
+	}
+
+	if r.DeadLetterStream != "" && r.DeadLetterStream == r.Stream {
+		return fmt.Errorf("Redis dead-letter stream must differ from the main stream")
+	}
+
+	if r.MaxLen < 0 || r.MinReplicas < 0 || r.Retries < 0 || r.LingerMs < 0 {
+		return fmt.Errorf("Redis max len, min replicas, retries and linger ms must be non-negative")
+	}
+
+	if r.BatchSize <= 0 || r.QueueSize <= 0 || r.MaxMessageBytes <= 0 || r.DeliveryTimeout <= 0 {
+		return fmt.Errorf("Redis batch size, queue size, max message bytes and delivery timeout must be positive")
+	}
+
+	return nil
+}
+
+// validate checks the webhook endpoints and delivery settings
+func (w WebhookConfig) validate() error {
+	if len(w.Endpoints) == 0 {
+		return fmt.Errorf("at least one webhook endpoint must be specified in WEBHOOK_ENDPOINTS")
+	}
+
+	for _, endpoint := range w.Endpoints {
+		if !sinkNamePattern.MatchString(endpoint.Name) {
+			return fmt.Errorf("invalid webhook endpoint name %q: use letters, digits and underscores", endpoint.Name)
+		}
+		parsed, err := url.Parse(endpoint.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("webhook endpoint %s needs an http or https URL", endpoint.Name)
+		}
+	}
+
+	if w.Timeout <= 0 || w.BackoffInitial <= 0 || w.BackoffMax < w.BackoffInitial {
+		return fmt.Errorf("webhook timeout and backoff must be positive, with max backoff at least the initial backoff")
+	}
+	if w.MaxRetries < 0 {
+		return fmt.Errorf("webhook max retries must be non-negative")
+	}
+	if w.Concurrency <= 0 || w.BatchSize <= 0 || w.BatchInterval <= 0 || w.QueueSize <= 0 {
+		return fmt.Errorf("webhook concurrency, batch size, batch interval and queue size must be positive")
+	}
+	if w.BreakerThreshold <= 0 || w.BreakerCooldown <= 0 {
+		return fmt.Errorf("webhook breaker threshold and cooldown must be positive")
+	}
+
+	return nil
+}
+
+// loadWebhookEndpoints reads the endpoints named in WEBHOOK_ENDPOINTS
+func loadWebhookEndpoints() []WebhookEndpoint {
+	var endpoints []WebhookEndpoint
+	for _, name := range parseList(getEnv("WEBHOOK_ENDPOINTS", "")) {
+		prefix := "WEBHOOK_" + strings.ToUpper(name) + "_"
+		endpoints = append(endpoints, WebhookEndpoint{
+			Name:       name,
+			URL:        getEnv(prefix+"URL", ""),
+			Secret:     getEnv(prefix+"SECRET", ""),
+			EventTypes: parseList(getEnv(prefix+"EVENT_TYPES", "")),
+		})
+	}
+	return endpoints
+}
+
+// loadSinks reads the sinks named in SINKS, each configured by SINK_<NAME>_* variables.
+// Without SINKS, the types listed in SINK_TYPE become sinks of the same name; the first is
+// required and the others best-effort.
+func loadSinks(kafka KafkaConfig) []SinkConfig {
+	names := parseList(os.Getenv("SINKS"))
+	explicit := len(names) > 0
+	if !explicit {
+		names = parseList(getEnv("SINK_TYPE", "kafka"))
+	}
+
+	sinks := make([]SinkConfig, 0, len(names))
+	for i, name := range names {
+		prefix := "SINK_" + strings.ToUpper(name) + "_"
+
+		sinkType, policy := name, "required"
+		if explicit {
+			sinkType = getEnv(prefix+"TYPE", name)
+			policy = getEnv(prefix+"POLICY", "required")
+		} else if i > 0 {
+			policy = "best_effort"
+		}
+
+		sinkKafka := kafka
+		sinkKafka.Brokers = parseList(getEnv(prefix+"BROKERS", strings.Join(kafka.Brokers, ",")))
+		sinkKafka.Topic = getEnv(prefix+"TOPIC", kafka.Topic)
+		if routes := os.Getenv(prefix + "TOPIC_ROUTES"); routes != "" {
+			sinkKafka.TopicRoutes = parseTopicRoutes(routes)
+		}
+		sinkKafka.DeadLetterTopic = getEnv(prefix+"DEAD_LETTER_TOPIC", kafka.DeadLetterTopic)
+		sinkKafka.SpoolDir = getEnv(prefix+"SPOOL_DIR", kafka.SpoolDir)
+
+		sinks = append(sinks, SinkConfig{
+			Name:       name,
+			Type:       sinkType,
+			Policy:     policy,
+			EventTypes: parseList(getEnv(prefix+"EVENT_TYPES", "")),
+			Tenants:    parseList(getEnv(prefix+"TENANTS", "")),
+
+			Kafka: sinkKafka,
+
+			MemoryMaxEvents: getEnvAsInt(prefix+"MAX_EVENTS", 10000),
+
+			FileDir:           getEnv(prefix+"DIR", "./data/events"),
+			FileMaxBytes:      int64(getEnvAsInt(prefix+"MAX_BYTES", 128*1024*1024)),
+			FileMaxAge:        getEnvAsDuration(prefix+"MAX_AGE", time.Hour),
+			FileGzip:          getEnvAsBool(prefix+"GZIP", true),
+			FileFlushInterval: getEnvAsDuration(prefix+"FLUSH_INTERVAL", time.Second),
+			FileMaxOpenFiles:  getEnvAsInt(prefix+"MAX_OPEN_FILES", 256),
+		})
+	}
+	return sinks
+}
+
+// validateEnrichmentSteps checks the pipeline steps. Step names are resolved when the
+// pipeline is built, since custom steps may be registered there.
+func (c *Config) validateEnrichmentSteps() error {
+	validPolicies := map[string]bool{"fail": true, "skip": true, "tag": true}
+	seen := make(map[string]bool, len(c.Enrichment.Steps))
+	for _, step := range c.Enrichment.Steps {
+		if seen[step.Name] {
+			return fmt.Errorf("enrichment step %s is listed more than once", step.Name)
+		}
+		seen[step.Name] = true
+
+		if step.Timeout <= 0 {
+			return fmt.Errorf("enrichment step %s timeout must be positive", step.Name)
+		}
+		if !validPolicies[step.OnError] {
+			return fmt.Errorf("invalid on_error policy for enrichment step %s: %s", step.Name, step.OnError)
+		}
+		if step.Name == "privacy" && step.OnError != "fail" {
+			// Skipping the step would publish events with privacy rules unapplied
+			return fmt.Errorf("the privacy enrichment step must use the fail on_error policy")
+		}
+	}
+
+	if seen["geoip"] && c.Enrichment.GeoIPCityDB == "" && c.Enrichment.GeoIPASNDB == "" {
+		return fmt.Errorf("the geoip enrichment step requires a GeoIP database")
+	}
+
+	if c.Privacy.Enabled() && !seen["privacy"] {
+		return fmt.Errorf("privacy rules are configured but the privacy enrichment step is not in the pipeline")
+	}
+
 	return nil
 }
 
+// loadEnrichmentSteps reads ENRICHMENT_PIPELINE and the per-step settings. Without an
+// explicit pipeline the built-in steps that are configured run, with privacy last so
+// earlier steps still see the full client IP.
+func loadEnrichmentSteps(enrichment EnrichmentConfig, privacy PrivacyConfig) []EnrichmentStep {
+	var names []string
+	if pipeline := os.Getenv("ENRICHMENT_PIPELINE"); pipeline != "" {
+		names = parseList(pipeline)
+	} else {
+		if enrichment.UserAgent {
+			names = append(names, "user_agent")
+		}
+		if enrichment.GeoIPCityDB != "" || enrichment.GeoIPASNDB != "" {
+			names = append(names, "geoip")
+		}
+		if privacy.Enabled() {
+			names = append(names, "privacy")
+		}
+	}
+
+	defaultTimeout := getEnvAsDuration("ENRICHMENT_STEP_TIMEOUT", 100*time.Millisecond)
+	steps := make([]EnrichmentStep, 0, len(names))
+	for _, name := range names {
+		// Events must never be published with privacy rules unapplied
+		defaultPolicy := "skip"
+		if name == "privacy" {
+			defaultPolicy = "fail"
+		}
+
+		prefix := "ENRICHMENT_" + strings.ToUpper(name) + "_"
+		steps = append(steps, EnrichmentStep{
+			Name:    name,
+			Timeout: getEnvAsDuration(prefix+"TIMEOUT", defaultTimeout),
+			OnError: getEnv(prefix+"ON_ERROR", defaultPolicy),
+		})
+	}
+	return steps
+}
+
+// Enabled reports whether any privacy rule is configured
+func (p PrivacyConfig) Enabled() bool {
+	return p.TruncateIP || len(p.DropKeys) > 0 || len(p.HashKeys) > 0 || len(p.Scrub) > 0
+}
+
 // getEnv gets environment variable with fallback
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
@@ -119,6 +955,36 @@ func getEnvAsInt(key string, fallback int) int {
 	return fallback
 }
 
+// getEnvAsFloat gets environment variable as float with fallback
+func getEnvAsFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return fallback
+}
+
+// getEnvAsBool gets environment variable as boolean with fallback
+func getEnvAsBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
+// getEnvAsDuration gets environment variable as duration with fallback
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return fallback
+}
+
 // parseBrokers parses comma-separated broker list
 func parseBrokers(brokers string) []string {
 	if brokers == "" {
@@ -126,3 +992,66 @@ func parseBrokers(brokers string) []string {
 	}
 	return strings.Split(brokers, ",")
 }
+
+// parseList parses a comma-separated list, dropping empty entries
+func parseList(list string) []string {
+	var parsed []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			parsed = append(parsed, item)
+		}
+	}
+	return parsed
+}
+
+// parseTopicRoutes parses comma-separated pattern=topic routing rules. A backslash before
+// a comma or equals sign makes it part of the pattern or topic, e.g. re:^a{1\,3}$=short.
+func parseTopicRoutes(routes string) []TopicRoute {
+	var parsed []TopicRoute
+	for _, route := range splitUnescaped(routes, ',') {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+
+		parts := splitUnescaped(route, '=')
+		parsed = append(parsed, TopicRoute{
+			Pattern: unescapeRoute(strings.TrimSpace(parts[0])),
+			Topic:   unescapeRoute(strings.TrimSpace(strings.Join(parts[1:], "="))),
+		})
+	}
+	return parsed
+}
+
+// splitUnescaped splits s at every sep that is not escaped with a backslash
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			// Skip the escaped character, so an escaped backslash does not escape sep
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeRoute removes the backslash from escaped commas and equals signs. Other escapes,
+// such as \d in a regex, are kept as written.
+func unescapeRoute(s string) string {
+	var unescaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if next := s[i+1]; next != ',' && next != '=' {
+				unescaped.WriteByte(s[i])
+			}
+			i++
+		}
+		unescaped.WriteByte(s[i])
+	}
+	return unescaped.String()
+}
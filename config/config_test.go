@@ -0,0 +1,160 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopicRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes string
+		want   []TopicRoute
+	}{
+		{
+			name:   "plain routes",
+			routes: "purchase_*=purchases, mouse_move=mouse-moves",
+			want: []TopicRoute{
+				{Pattern: "purchase_*", Topic: "purchases"},
+				{Pattern: "mouse_move", Topic: "mouse-moves"},
+			},
+		},
+		{
+			name:   "escaped comma in a regex",
+			routes: `re:^step_\d{1\,2}$=funnel,click=clicks`,
+			want: []TopicRoute{
+				{Pattern: `re:^step_\d{1,2}$`, Topic: "funnel"},
+				{Pattern: "click", Topic: "clicks"},
+			},
+		},
+		{
+			name:   "escaped equals sign",
+			routes: `re:^a\=b$=ab`,
+			want:   []TopicRoute{{Pattern: `re:^a=b$`, Topic: "ab"}},
+		},
+		{
+			name:   "escaped backslash before a separator",
+			routes: `re:a\\=slash,b=bees`,
+			want: []TopicRoute{
+				{Pattern: `re:a\\`, Topic: "slash"},
+				{Pattern: "b", Topic: "bees"},
+			},
+		},
+		{
+			name:   "empty entries are skipped",
+			routes: ",a=b,,",
+			want:   []TopicRoute{{Pattern: "a", Topic: "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTopicRoutes(tt.routes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTopicRoutes(%q) = %+v, want %+v", tt.routes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicRouteValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{pattern: "page_view"},
+		{pattern: "purchase_*"},
+		{pattern: `re:^step_\d{1,2}$`},
+		{pattern: "re:^(unclosed$", wantErr: true},
+		{pattern: "purchase_[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := TopicRoute{Pattern: tt.pattern, Topic: "topic"}.validatePattern()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePattern(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigKafkaSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		sinks   string
+		wantErr bool
+	}{
+		{name: "invalid Kafka settings without a kafka sink", sinks: "memory"},
+		{name: "invalid Kafka settings with a kafka sink", sinks: "memory,kafka", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SINK_TYPE", tt.sinks)
+			t.Setenv("KAFKA_ACKS", "sometimes")
+			t.Setenv("KAFKA_COMPRESSION", "rar")
+
+			_, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigConfirmDelivery(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    string
+		deprecated string
+		want       bool
+	}{
+		{name: "unset", want: false},
+		{name: "CONFIRM_DELIVERY", confirm: "true", want: true},
+		{name: "deprecated KAFKA_CONFIRM_DELIVERY", deprecated: "true", want: true},
+		{name: "CONFIRM_DELIVERY takes precedence", confirm: "false", deprecated: "true", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIRM_DELIVERY", tt.confirm)
+			t.Setenv("KAFKA_CONFIRM_DELIVERY", tt.deprecated)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.Server.ConfirmDelivery != tt.want {
+				t.Errorf("ConfirmDelivery = %v, want %v", cfg.Server.ConfirmDelivery, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileSinkDirs(t *testing.T) {
+	tests := []struct {
+		name    string
+		dirA    string
+		dirB    string
+		wantErr bool
+	}{
+		{name: "default directories", wantErr: true},
+		{name: "same directory spelled differently", dirA: "./data/events", dirB: "data/events/", wantErr: true},
+		{name: "separate directories", dirA: "./data/a", dirB: "./data/b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SINKS", "a,b")
+			t.Setenv("SINK_A_TYPE", "file")
+			t.Setenv("SINK_B_TYPE", "file")
+			t.Setenv("SINK_A_DIR", tt.dirA)
+			t.Setenv("SINK_B_DIR", tt.dirB)
+
+			_, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache remembers idempotency keys for a time window. It holds at most maxEntries
+// keys; when full, the oldest confirmed key is evicted early. Pending keys are never
+// evicted, so the cache may briefly exceed maxEntries while they are all in flight.
+type Cache struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	evicted    uint64
+}
+
+// entry is a claimed idempotency key
+type entry struct {
+	key     string
+	eventID string
+	expires time.Time
+	// pending is set until the first claim's event is confirmed as published
+	pending bool
+}
+
+// Status is the outcome of claiming a key
+type Status int
+
+const (
+	// Claimed means the key is new and now belongs to the caller
+	Claimed Status = iota
+	// Duplicate means the key's event was already published
+	Duplicate
+	// InFlight means the key's first event is still being published, so the caller
+	// cannot yet tell whether it will succeed and should retry later
+	InFlight
+)
+
+// NewCache creates a cache remembering keys for window
+func NewCache(window time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Claim records the key for eventID as pending until Confirm or Release. When the key
+// was already claimed within the window it returns the event ID of the first claim and
+// whether that event was published or is still in flight.
+func (c *Cache) Claim(key, eventID string) (string, Status) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(now)
+
+	if element, ok := c.entries[key]; ok {
+		existing := element.Value.(*entry)
+		if existing.pending {
+			return existing.eventID, InFlight
+		}
+		return existing.eventID, Duplicate
+	}
+
+	if c.order.Len() >= c.maxEntries {
+		c.evictConfirmed()
+	}
+
+	c.entries[key] = c.order.PushBack(&entry{
+		key:     key,
+		eventID: eventID,
+		expires: now.Add(c.window),
+		pending: true,
+	})
+	return eventID, Claimed
+}
+
+// Confirm marks a claimed key's event as published, so repeats are acknowledged as duplicates
+func (c *Cache) Confirm(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*entry).pending = false
+	}
+}
+
+// Release forgets a claimed key, so a retry after a failed publish is not treated as a duplicate
+func (c *Cache) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Stats returns the number of remembered keys and of keys evicted before their window ended
+func (c *Cache) Stats() (int, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len(), c.evicted
+}
+
+// evictConfirmed drops the oldest key whose event was published. Evicting a pending key
+// would let a concurrent repeat publish the event a second time.
+func (c *Cache) evictConfirmed() {
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		if !element.Value.(*entry).pending {
+			c.remove(element)
+			c.evicted++
+			return
+		}
+	}
+}
+
+// expire drops keys whose window has ended; entries are ordered by expiry
+func (c *Cache) expire(now time.Time) {
+	for element := c.order.Front(); element != nil; element = c.order.Front() {
+		if now.Before(element.Value.(*entry).expires) {
+			return
+		}
+		c.remove(element)
+	}
+}
+
+// remove deletes an entry from both indexes
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
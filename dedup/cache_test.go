@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheClaim(t *testing.T) {
+	cache := NewCache(time.Hour, 10)
+
+	steps := []struct {
+		action     string
+		key        string
+		eventID    string
+		wantID     string
+		wantStatus Status
+	}{
+		{action: "claim", key: "a", eventID: "evt-1", wantID: "evt-1", wantStatus: Claimed},
+		// The first event may still fail, so a repeat is told to retry
+		{action: "claim", key: "a", eventID: "evt-2", wantID: "evt-1", wantStatus: InFlight},
+		{action: "confirm", key: "a"},
+		{action: "claim", key: "a", eventID: "evt-3", wantID: "evt-1", wantStatus: Duplicate},
+		{action: "claim", key: "b", eventID: "evt-4", wantID: "evt-4", wantStatus: Claimed},
+		// A failed publish releases the key, so the retry claims it again
+		{action: "release", key: "b"},
+		{action: "claim", key: "b", eventID: "evt-5", wantID: "evt-5", wantStatus: Claimed},
+	}
+
+	for i, step := range steps {
+		switch step.action {
+		case "confirm":
+			cache.Confirm(step.key)
+		case "release":
+			cache.Release(step.key)
+		default:
+			id, status := cache.Claim(step.key, step.eventID)
+			if id != step.wantID || status != step.wantStatus {
+				t.Errorf("step %d: Claim(%q) = %q, %v, want %q, %v", i, step.key, id, status, step.wantID, step.wantStatus)
+			}
+		}
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	cache := NewCache(10*time.Millisecond, 10)
+
+	cache.Claim("a", "evt-1")
+	cache.Confirm("a")
+	time.Sleep(20 * time.Millisecond)
+
+	if id, status := cache.Claim("a", "evt-2"); id != "evt-2" || status != Claimed {
+		t.Errorf("Claim after the window = %q, %v, want evt-2, Claimed", id, status)
+	}
+}
+
+func TestCacheEvictionSkipsPending(t *testing.T) {
+	cache := NewCache(time.Hour, 2)
+
+	cache.Claim("pending", "evt-1")
+	cache.Claim("confirmed", "evt-2")
+	cache.Confirm("confirmed")
+
+	// The cache is full; the oldest key is still in flight, so the confirmed one goes
+	cache.Claim("new", "evt-3")
+	if _, status := cache.Claim("pending", "evt-4"); status != InFlight {
+		t.Errorf("pending key status = %v, want InFlight", status)
+	}
+	if _, status := cache.Claim("confirmed", "evt-5"); status != Claimed {
+		t.Errorf("confirmed key status = %v, want Claimed after eviction", status)
+	}
+
+	// Every key is pending now, so the cache grows past its limit rather than evicting one
+	if entries, evicted := cache.Stats(); entries != 3 || evicted != 1 {
+		t.Errorf("Stats() = %d entries, %d evicted, want 3 and 1", entries, evicted)
+	}
+}
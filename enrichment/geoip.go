@@ -0,0 +1,274 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"ingestion-service/models"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// GeoIPConfig holds the MaxMind-format databases to resolve client IPs against
+type GeoIPConfig struct {
+	// CityDB is a GeoIP2/GeoLite2 City (or Country) database; empty disables location lookups
+	CityDB string
+	// ASNDB is a GeoIP2/GeoLite2 ASN database; empty disables ASN lookups
+	ASNDB string
+	// ReloadInterval is how often the files are checked for changes; 0 disables reloading
+	ReloadInterval time.Duration
+}
+
+// GeoIPResolver resolves client IPs to location and network fields. Databases are
+// read into memory, so files can be replaced on disk at any time; changed files are
+// picked up on the next reload check.
+type GeoIPResolver struct {
+	city   *mmdbFile
+	asn    *mmdbFile
+	logger *zap.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// mmdbFile is a database loaded from disk along with the modification time it was read at
+type mmdbFile struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+// cityRecord is the subset of a City database record the resolver reads
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		TimeZone string `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// asnRecord is an ASN database record
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewGeoIPResolver loads the configured databases and, when ReloadInterval is
+// positive, watches them for changes
+func NewGeoIPResolver(config GeoIPConfig, logger *zap.Logger) (*GeoIPResolver, error) {
+	if config.CityDB == "" && config.ASNDB == "" {
+		return nil, fmt.Errorf("at least one GeoIP database must be specified")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resolver := &GeoIPResolver{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, db := range []struct {
+		path   string
+		target **mmdbFile
+	}{
+		{config.CityDB, &resolver.city},
+		{config.ASNDB, &resolver.asn},
+	} {
+		if db.path == "" {
+			continue
+		}
+		file := &mmdbFile{path: db.path}
+		if err := file.load(); err != nil {
+			cancel()
+			return nil, err
+		}
+		*db.target = file
+	}
+
+	if config.ReloadInterval > 0 {
+		go resolver.watch(config.ReloadInterval)
+	}
+
+	return resolver, nil
+}
+
+// Name returns the pipeline step name
+func (r *GeoIPResolver) Name() string {
+	return StepGeoIP
+}
+
+// Enrich resolves the event's client IP and stores the result on the event.
+// Private, loopback and unknown addresses are left without geo fields.
+func (r *GeoIPResolver) Enrich(ctx context.Context, event *models.EnrichedEvent) error {
+	ip := net.ParseIP(event.ClientIP)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() {
+		return nil
+	}
+
+	if geo, ok := r.Lookup(ip); ok {
+		event.Geo = &geo
+	}
+	return nil
+}
+
+// Lookup resolves an IP and reports whether any database had a record for it
+func (r *GeoIPResolver) Lookup(ip net.IP) (models.GeoInfo, bool) {
+	var geo models.GeoInfo
+	found := false
+
+	if r.city != nil {
+		var record cityRecord
+		if ok, err := r.city.lookup(ip, &record); err != nil {
+			r.logger.Debug("GeoIP city lookup failed", zap.Error(err))
+		} else if ok {
+			found = true
+			geo.CountryCode = record.Country.ISOCode
+			geo.Country = record.Country.Names["en"]
+			if len(record.Subdivisions) > 0 {
+				geo.RegionCode = record.Subdivisions[0].ISOCode
+				geo.Region = record.Subdivisions[0].Names["en"]
+			}
+			geo.City = record.City.Names["en"]
+			geo.TimeZone = record.Location.TimeZone
+		}
+	}
+
+	if r.asn != nil {
+		var record asnRecord
+		if ok, err := r.asn.lookup(ip, &record); err != nil {
+			r.logger.Debug("GeoIP ASN lookup failed", zap.Error(err))
+		} else if ok {
+			found = true
+			geo.ASN = record.Number
+			geo.ASOrganization = record.Organization
+		}
+	}
+
+	return geo, found
+}
+
+// Stats returns the loaded databases and their build dates
+func (r *GeoIPResolver) Stats() map[string]interface{} {
+	stats := make(map[string]interface{})
+	for name, file := range map[string]*mmdbFile{"city": r.city, "asn": r.asn} {
+		if file != nil {
+			stats[name] = file.stats()
+		}
+	}
+	return stats
+}
+
+// Close stops watching the database files
+func (r *GeoIPResolver) Close() {
+	r.cancel()
+}
+
+// watch reloads databases whose modification time changed. A file that fails to
+// load is logged and the previous database stays active.
+func (r *GeoIPResolver) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, file := range []*mmdbFile{r.city, r.asn} {
+				if file == nil {
+					continue
+				}
+
+				changed, err := file.changed()
+				if err != nil {
+					r.logger.Warn("Failed to stat GeoIP database", zap.String("path", file.path), zap.Error(err))
+					continue
+				}
+				if !changed {
+					continue
+				}
+
+				if err := file.load(); err != nil {
+					r.logger.Error("Failed to reload GeoIP database, keeping previous version",
+						zap.String("path", file.path),
+						zap.Error(err),
+					)
+					continue
+				}
+				r.logger.Info("GeoIP database reloaded", zap.String("path", file.path))
+			}
+		}
+	}
+}
+
+// load reads the database into memory and replaces the active reader
+func (f *mmdbFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat GeoIP database: %w", err)
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to open GeoIP database %s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.reader = reader
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+
+	return nil
+}
+
+// changed reports whether the file on disk differs from the loaded one
+func (f *mmdbFile) changed() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return !info.ModTime().Equal(f.modTime), nil
+}
+
+// lookup decodes the record for ip into result and reports whether one was found
+func (f *mmdbFile) lookup(ip net.IP, result interface{}) (bool, error) {
+	f.mu.RLock()
+	reader := f.reader
+	f.mu.RUnlock()
+
+	_, ok, err := reader.LookupNetwork(ip, result)
+	return ok, err
+}
+
+// stats describes the loaded database
+func (f *mmdbFile) stats() map[string]interface{} {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return map[string]interface{}{
+		"path":          f.path,
+		"database_type": f.reader.Metadata.DatabaseType,
+		"build_time":    time.Unix(int64(f.reader.Metadata.BuildEpoch), 0).UTC(),
+	}
+}
@@ -0,0 +1,246 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Enricher adds fields to, or transforms, an event between validation and publish.
+// Implementations must honour ctx cancellation; a step that overruns its timeout
+// has its changes discarded.
+type Enricher interface {
+	// Name identifies the step in configuration, metrics and logs
+	Name() string
+	Enrich(ctx context.Context, event *models.EnrichedEvent) error
+}
+
+// Names of the built-in steps
+const (
+	StepUserAgent = "user_agent"
+	StepGeoIP     = "geoip"
+	StepPrivacy   = "privacy"
+)
+
+// ErrorPolicy decides what happens to an event when a step fails or times out
+type ErrorPolicy string
+
+const (
+	// OnErrorFail rejects the event
+	OnErrorFail ErrorPolicy = "fail"
+	// OnErrorSkip publishes the event without the step's changes
+	OnErrorSkip ErrorPolicy = "skip"
+	// OnErrorTag publishes the event without the step's changes and lists the step in enrichment_errors
+	OnErrorTag ErrorPolicy = "tag"
+)
+
+// StepConfig configures one pipeline step
+type StepConfig struct {
+	Name    string
+	Timeout time.Duration
+	OnError ErrorPolicy
+}
+
+// StepError reports a failed step whose policy rejects the event
+type StepError struct {
+	Step string
+	Err  error
+}
+
+// Error returns the step name and cause
+func (e *StepError) Error() string {
+	return fmt.Sprintf("enrichment step %s failed: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the cause
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Factory creates the enricher for a step
+type Factory func() (Enricher, error)
+
+// Registry maps step names to the factories that build them
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register makes a step available to pipelines under name
+func (r *Registry) Register(name string, factory Factory) {
+	r.factories[name] = factory
+}
+
+// Build creates a pipeline running the configured steps in order
+func (r *Registry) Build(steps []StepConfig, logger *zap.Logger) (*Pipeline, error) {
+	pipeline := &Pipeline{logger: logger}
+	for _, config := range steps {
+		factory, ok := r.factories[config.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown enrichment step %q", config.Name)
+		}
+
+		switch config.OnError {
+		case OnErrorFail, OnErrorSkip, OnErrorTag:
+		default:
+			return nil, fmt.Errorf("invalid error policy %q for enrichment step %s", config.OnError, config.Name)
+		}
+
+		if config.Timeout <= 0 {
+			return nil, fmt.Errorf("enrichment step %s timeout must be positive", config.Name)
+		}
+
+		enricher, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create enrichment step %s: %w", config.Name, err)
+		}
+
+		pipeline.steps = append(pipeline.steps, pipelineStep{config: config, enricher: enricher})
+	}
+	return pipeline, nil
+}
+
+// Pipeline runs enrichment steps in order
+type Pipeline struct {
+	steps  []pipelineStep
+	logger *zap.Logger
+}
+
+// pipelineStep is a configured enricher
+type pipelineStep struct {
+	config   StepConfig
+	enricher Enricher
+}
+
+// Run applies every step to the event. It returns a *StepError when a step with the
+// fail policy fails; other failures are logged and leave the event as it was before the step.
+func (p *Pipeline) Run(ctx context.Context, event *models.EnrichedEvent) error {
+	for _, step := range p.steps {
+		name := step.config.Name
+		start := time.Now()
+		err := step.run(ctx, event)
+		metrics.EnrichmentDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err == nil {
+			continue
+		}
+
+		reason := "error"
+		if errors.Is(err, context.DeadlineExceeded) {
+			reason = "timeout"
+		}
+		metrics.EnrichmentErrors.WithLabelValues(name, reason).Inc()
+
+		p.logger.Warn("Enrichment step failed",
+			zap.String("step", name),
+			zap.String("event_id", event.EventID),
+			zap.String("on_error", string(step.config.OnError)),
+			zap.Error(err),
+		)
+
+		switch step.config.OnError {
+		case OnErrorFail:
+			return &StepError{Step: name, Err: err}
+		case OnErrorTag:
+			event.EnrichmentErrors = append(event.EnrichmentErrors, name)
+		}
+	}
+	return nil
+}
+
+// Steps returns the configured steps in order
+func (p *Pipeline) Steps() []StepConfig {
+	steps := make([]StepConfig, len(p.steps))
+	for i, step := range p.steps {
+		steps[i] = step.config
+	}
+	return steps
+}
+
+// run applies the step to a copy of the event within the step timeout, and keeps
+// the copy only when the step succeeds in time
+func (s pipelineStep) run(ctx context.Context, event *models.EnrichedEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	candidate := cloneEvent(*event)
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+		done <- s.enricher.Enrich(ctx, &candidate)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		*event = candidate
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cloneEvent deep-copies the parts of an event a step may modify in place
+func cloneEvent(event models.EnrichedEvent) models.EnrichedEvent {
+	event.EventData = cloneObject(event.EventData)
+	if event.UserAgentInfo != nil {
+		info := *event.UserAgentInfo
+		event.UserAgentInfo = &info
+	}
+	if event.Geo != nil {
+		geo := *event.Geo
+		event.Geo = &geo
+	}
+	if event.Privacy != nil {
+		privacy := models.PrivacyInfo{AppliedRules: append([]string(nil), event.Privacy.AppliedRules...)}
+		event.Privacy = &privacy
+	}
+	if event.TraceContext != nil {
+		trace := *event.TraceContext
+		event.TraceContext = &trace
+	}
+	event.EnrichmentErrors = append([]string(nil), event.EnrichmentErrors...)
+	return event
+}
+
+// cloneObject deep-copies a JSON object
+func cloneObject(object map[string]interface{}) map[string]interface{} {
+	if object == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		clone[key] = cloneValue(value)
+	}
+	return clone
+}
+
+// cloneValue deep-copies a JSON value
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return cloneObject(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	default:
+		return value
+	}
+}
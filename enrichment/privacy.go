@@ -0,0 +1,390 @@
+package enrichment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"ingestion-service/models"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Privacy rule names recorded on events in PrivacyInfo.AppliedRules
+const (
+	RuleTruncateIP = "truncate_ip"
+	RuleDropKeys   = "drop_keys"
+	RuleHashKeys   = "hash_keys"
+	RuleScrubEmail = "scrub_email"
+	RuleScrubPhone = "scrub_phone"
+	RuleScrubCard  = "scrub_card"
+)
+
+// PrivacyConfig holds the privacy rules applied to events before they are published
+type PrivacyConfig struct {
+	// TruncateIP zeroes the host part of client IPs beyond the given prefix lengths
+	TruncateIP bool
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// DropKeys and HashKeys name event_data keys, matched case-insensitively at any depth
+	DropKeys []string
+	HashKeys []string
+	// HashSalt keys the HMAC-SHA256 used for hashed values
+	HashSalt string
+
+	// Scrub lists the detectors applied to string values: email, phone and card
+	Scrub []string
+}
+
+// scrubber replaces one kind of PII in free text
+type scrubber struct {
+	rule        string
+	pattern     *regexp.Regexp
+	replacement string
+	// valid confirms a match before it is replaced; nil accepts every match
+	valid func(match string) bool
+}
+
+// scrubbers are the available PII detectors by name
+var scrubbers = map[string]scrubber{
+	"card": {
+		rule:        RuleScrubCard,
+		pattern:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		replacement: "[REDACTED_CARD]",
+		valid:       luhnValid,
+	},
+	"email": {
+		rule:        RuleScrubEmail,
+		pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		replacement: "[REDACTED_EMAIL]",
+	},
+	"phone": {
+		rule:        RuleScrubPhone,
+		pattern:     regexp.MustCompile(`\+\d{1,3}(?:[\s.\-]?\(?\d{1,4}\)?){2,5}|\(?\b\d{3}\)?[\s.\-]\d{3}[\s.\-]\d{4}\b`),
+		replacement: "[REDACTED_PHONE]",
+	},
+}
+
+// scrubberOrder runs card numbers first so they are not partially matched as phone numbers
+var scrubberOrder = []string{"card", "email", "phone"}
+
+// PrivacyFilter applies privacy rules to enriched events
+type PrivacyFilter struct {
+	config    PrivacyConfig
+	ipv4Mask  net.IPMask
+	ipv6Mask  net.IPMask
+	dropKeys  map[string]bool
+	hashKeys  map[string]bool
+	scrubbers []scrubber
+}
+
+// NewPrivacyFilter validates the rules and creates a filter
+func NewPrivacyFilter(config PrivacyConfig) (*PrivacyFilter, error) {
+	if config.TruncateIP {
+		if config.IPv4Prefix < 0 || config.IPv4Prefix > 32 {
+			return nil, fmt.Errorf("IPv4 prefix must be between 0 and 32")
+		}
+		if config.IPv6Prefix < 0 || config.IPv6Prefix > 128 {
+			return nil, fmt.Errorf("IPv6 prefix must be between 0 and 128")
+		}
+	}
+
+	if len(config.HashKeys) > 0 && config.HashSalt == "" {
+		return nil, fmt.Errorf("a hash salt is required to hash event_data keys")
+	}
+
+	filter := &PrivacyFilter{
+		config:   config,
+		ipv4Mask: net.CIDRMask(config.IPv4Prefix, 32),
+		ipv6Mask: net.CIDRMask(config.IPv6Prefix, 128),
+		dropKeys: keySet(config.DropKeys),
+		hashKeys: keySet(config.HashKeys),
+	}
+
+	enabled := make(map[string]bool, len(config.Scrub))
+	for _, name := range config.Scrub {
+		if _, ok := scrubbers[name]; !ok {
+			return nil, fmt.Errorf("unknown scrub detector %q", name)
+		}
+		enabled[name] = true
+	}
+	for _, name := range scrubberOrder {
+		if enabled[name] {
+			filter.scrubbers = append(filter.scrubbers, scrubbers[name])
+		}
+	}
+
+	return filter, nil
+}
+
+// Apply enforces the privacy rules on the event and records the rules that changed it
+func (f *PrivacyFilter) Apply(event *models.EnrichedEvent) {
+	applied := make(map[string]bool)
+
+	if f.config.TruncateIP && event.ClientIP != "" {
+		if truncated := f.truncateIP(event.ClientIP); truncated != event.ClientIP {
+			event.ClientIP = truncated
+			applied[RuleTruncateIP] = true
+		}
+	}
+
+	if event.EventData != nil {
+		event.EventData = f.filterObject(event.EventData, applied)
+	}
+
+	// Query values are scrubbed decoded, and the rest of the URL as text, as in FilterPayload
+	if len(f.scrubbers) > 0 && event.PageURL != "" {
+		event.PageURL = f.scrub(f.scrubQuery(event.PageURL, applied), applied)
+	}
+
+	if len(applied) == 0 {
+		return
+	}
+
+	rules := make([]string, 0, len(applied))
+	for rule := range applied {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	event.Privacy = &models.PrivacyInfo{AppliedRules: rules}
+}
+
+// Name returns the pipeline step name
+func (f *PrivacyFilter) Name() string {
+	return StepPrivacy
+}
+
+// Enrich applies the privacy rules as a pipeline step
+func (f *PrivacyFilter) Enrich(ctx context.Context, event *models.EnrichedEvent) error {
+	f.Apply(event)
+	return nil
+}
+
+// ScrubText applies the scrub detectors to free text, such as a payload that failed to parse
+func (f *PrivacyFilter) ScrubText(text string) string {
+	return f.scrub(text, make(map[string]bool))
+}
+
+// FilterPayload applies the privacy rules to a raw payload, such as one being dead-lettered.
+// A JSON payload has keys dropped or hashed and strings scrubbed at any depth, and its
+// client_ip and page_url fields treated as on events; anything else gets the text scrubbers.
+func (f *PrivacyFilter) FilterPayload(raw []byte) []byte {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return []byte(f.ScrubText(string(raw)))
+	}
+
+	filtered, err := json.Marshal(f.filterDocument(document, make(map[string]bool)))
+	if err != nil {
+		return []byte(f.ScrubText(string(raw)))
+	}
+	return filtered
+}
+
+// filterDocument applies every rule to a parsed payload of unknown shape, such as a single
+// event or a batch of them
+func (f *PrivacyFilter) filterDocument(value interface{}, applied map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		filtered := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			name := strings.ToLower(key)
+			text, isText := field.(string)
+			switch {
+			case f.dropKeys[name]:
+				applied[RuleDropKeys] = true
+			case f.hashKeys[name]:
+				if field != nil {
+					field = f.hash(field)
+					applied[RuleHashKeys] = true
+				}
+				filtered[key] = field
+			case name == "client_ip" && isText:
+				if f.config.TruncateIP {
+					text = f.truncateIP(text)
+				}
+				filtered[key] = text
+			case name == "page_url" && isText:
+				filtered[key] = f.scrub(f.scrubQuery(text, applied), applied)
+			default:
+				filtered[key] = f.filterDocument(field, applied)
+			}
+		}
+		return filtered
+	case []interface{}:
+		filtered := make([]interface{}, len(v))
+		for i, item := range v {
+			filtered[i] = f.filterDocument(item, applied)
+		}
+		return filtered
+	case string:
+		return f.scrub(v, applied)
+	default:
+		return value
+	}
+}
+
+// Rules returns the configured rules
+func (f *PrivacyFilter) Rules() []string {
+	var rules []string
+	if f.config.TruncateIP {
+		rules = append(rules, RuleTruncateIP)
+	}
+	if len(f.dropKeys) > 0 {
+		rules = append(rules, RuleDropKeys)
+	}
+	if len(f.hashKeys) > 0 {
+		rules = append(rules, RuleHashKeys)
+	}
+	for _, s := range f.scrubbers {
+		rules = append(rules, s.rule)
+	}
+	return rules
+}
+
+// truncateIP masks an IP address to the configured prefix
+func (f *PrivacyFilter) truncateIP(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return value
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(f.ipv4Mask).String()
+	}
+	return ip.Mask(f.ipv6Mask).String()
+}
+
+// filterObject returns a copy of the object with keys dropped, hashed or scrubbed
+func (f *PrivacyFilter) filterObject(object map[string]interface{}, applied map[string]bool) map[string]interface{} {
+	filtered := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		name := strings.ToLower(key)
+		switch {
+		case f.dropKeys[name]:
+			applied[RuleDropKeys] = true
+		case f.hashKeys[name]:
+			if value != nil {
+				value = f.hash(value)
+				applied[RuleHashKeys] = true
+			}
+			filtered[key] = value
+		default:
+			filtered[key] = f.filterValue(value, applied)
+		}
+	}
+	return filtered
+}
+
+// filterValue applies the rules to a value of any JSON type
+func (f *PrivacyFilter) filterValue(value interface{}, applied map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return f.filterObject(v, applied)
+	case []interface{}:
+		filtered := make([]interface{}, len(v))
+		for i, item := range v {
+			filtered[i] = f.filterValue(item, applied)
+		}
+		return filtered
+	case string:
+		return f.scrub(v, applied)
+	default:
+		return value
+	}
+}
+
+// hash returns the salted HMAC-SHA256 of a value, so equal values still correlate
+func (f *PrivacyFilter) hash(value interface{}) string {
+	text, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		text = string(encoded)
+	}
+
+	mac := hmac.New(sha256.New, []byte(f.config.HashSalt))
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// scrub replaces detected PII in a string
+func (f *PrivacyFilter) scrub(text string, applied map[string]bool) string {
+	for _, s := range f.scrubbers {
+		text = s.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if s.valid != nil && !s.valid(match) {
+				return match
+			}
+			applied[s.rule] = true
+			return s.replacement
+		})
+	}
+	return text
+}
+
+// scrubQuery scrubs the query parameter values of a URL, keeping their order
+func (f *PrivacyFilter) scrubQuery(rawURL string, applied map[string]bool) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawQuery == "" {
+		return rawURL
+	}
+
+	params := strings.Split(parsed.RawQuery, "&")
+	changed := false
+	for i, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		if scrubbed := f.scrub(decoded, applied); scrubbed != decoded {
+			params[i] = key + "=" + url.QueryEscape(scrubbed)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return rawURL
+	}
+	parsed.RawQuery = strings.Join(params, "&")
+	return parsed.String()
+}
+
+// keySet builds a lower-cased lookup set
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[strings.ToLower(key)] = true
+	}
+	return set
+}
+
+// luhnValid reports whether the digits in a candidate card number pass the Luhn checksum
+func luhnValid(candidate string) bool {
+	sum, count := 0, 0
+	double := false
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+		count++
+	}
+	return count >= 13 && count <= 19 && sum%10 == 0
+}
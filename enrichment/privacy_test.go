@@ -0,0 +1,121 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"ingestion-service/models"
+	"reflect"
+	"testing"
+)
+
+func TestPrivacyFilterEnrich(t *testing.T) {
+	filter, err := NewPrivacyFilter(PrivacyConfig{
+		TruncateIP: true,
+		IPv4Prefix: 24,
+		IPv6Prefix: 48,
+		DropKeys:   []string{"password"},
+		HashKeys:   []string{"email"},
+		HashSalt:   "salt",
+		Scrub:      []string{"email", "phone", "card"},
+	})
+	if err != nil {
+		t.Fatalf("NewPrivacyFilter: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		event     models.EnrichedEvent
+		wantIP    string
+		wantURL   string
+		wantData  map[string]interface{}
+		wantRules []string
+	}{
+		{
+			name:    "nothing to change",
+			event:   models.EnrichedEvent{PageURL: "https://example.com/pricing?plan=pro"},
+			wantURL: "https://example.com/pricing?plan=pro",
+		},
+		{
+			name:      "client IP truncated",
+			event:     models.EnrichedEvent{ClientIP: "203.0.113.57"},
+			wantIP:    "203.0.113.0",
+			wantRules: []string{RuleTruncateIP},
+		},
+		{
+			name:      "encoded query value",
+			event:     models.EnrichedEvent{PageURL: "https://example.com/signup?contact=jane%40example.com&step=2"},
+			wantURL:   "https://example.com/signup?contact=%5BREDACTED_EMAIL%5D&step=2",
+			wantRules: []string{RuleScrubEmail},
+		},
+		{
+			name:      "path outside the query",
+			event:     models.EnrichedEvent{PageURL: "https://example.com/users/jane@example.com/orders"},
+			wantURL:   "https://example.com/users/[REDACTED_EMAIL]/orders",
+			wantRules: []string{RuleScrubEmail},
+		},
+		{
+			name: "event_data keys and values",
+			event: models.EnrichedEvent{EventData: map[string]interface{}{
+				"Password": "hunter2",
+				"email":    "jane@example.com",
+				"note":     "card 4111 1111 1111 1111, call +1 415 555 0100",
+				"items":    []interface{}{"order 1234"},
+			}},
+			wantData: map[string]interface{}{
+				// HMAC-SHA256 of jane@example.com keyed by "salt"
+				"email": "a100ddb709a49d478230dc673238267a10448f7583d81fd97775cd71c9ee3a76",
+				"note":  "card [REDACTED_CARD], call [REDACTED_PHONE]",
+				"items": []interface{}{"order 1234"},
+			},
+			wantRules: []string{RuleDropKeys, RuleHashKeys, RuleScrubCard, RuleScrubPhone},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event
+			if err := filter.Enrich(context.Background(), &event); err != nil {
+				t.Fatalf("Enrich: %v", err)
+			}
+
+			if event.ClientIP != tt.wantIP {
+				t.Errorf("client IP = %q, want %q", event.ClientIP, tt.wantIP)
+			}
+			if event.PageURL != tt.wantURL {
+				t.Errorf("page URL = %q, want %q", event.PageURL, tt.wantURL)
+			}
+			if tt.wantData != nil && !reflect.DeepEqual(event.EventData, tt.wantData) {
+				t.Errorf("event_data = %v, want %v", event.EventData, tt.wantData)
+			}
+
+			var rules []string
+			if event.Privacy != nil {
+				rules = event.Privacy.AppliedRules
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("applied rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestPrivacyFilterPageURLMatchesPayload(t *testing.T) {
+	filter, err := NewPrivacyFilter(PrivacyConfig{Scrub: []string{"email"}})
+	if err != nil {
+		t.Fatalf("NewPrivacyFilter: %v", err)
+	}
+
+	// Published events and dead-lettered payloads must redact page_url alike
+	pageURL := "https://example.com/users/jane@example.com?ref=bob%40example.com"
+	event := models.EnrichedEvent{PageURL: pageURL}
+	filter.Apply(&event)
+
+	raw, _ := json.Marshal(map[string]string{"page_url": pageURL})
+	var payload map[string]string
+	if err := json.Unmarshal(filter.FilterPayload(raw), &payload); err != nil {
+		t.Fatalf("decoding filtered payload: %v", err)
+	}
+	if event.PageURL != payload["page_url"] {
+		t.Errorf("event page URL = %q, payload page URL = %q", event.PageURL, payload["page_url"])
+	}
+}
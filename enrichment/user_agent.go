@@ -0,0 +1,114 @@
+package enrichment
+
+import (
+	"context"
+	"ingestion-service/models"
+	"strings"
+	"sync"
+
+	"github.com/mssola/useragent"
+)
+
+// Device types reported in UserAgentInfo.DeviceType
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// UserAgentParser turns user agent strings into structured fields. Results are
+// cached because a handful of user agents make up most traffic.
+type UserAgentParser struct {
+	mu        sync.RWMutex
+	cache     map[string]models.UserAgentInfo
+	cacheSize int
+}
+
+// NewUserAgentParser creates a parser caching up to cacheSize distinct user agents
+func NewUserAgentParser(cacheSize int) *UserAgentParser {
+	return &UserAgentParser{
+		cache:     make(map[string]models.UserAgentInfo),
+		cacheSize: cacheSize,
+	}
+}
+
+// Name returns the pipeline step name
+func (p *UserAgentParser) Name() string {
+	return StepUserAgent
+}
+
+// Enrich parses the event's user agent and stores the result on the event
+func (p *UserAgentParser) Enrich(ctx context.Context, event *models.EnrichedEvent) error {
+	if event.ClientInfo.UserAgent == "" {
+		return nil
+	}
+
+	info := p.Parse(event.ClientInfo.UserAgent)
+	event.UserAgentInfo = &info
+	return nil
+}
+
+// Parse returns the structured fields for a user agent string
+func (p *UserAgentParser) Parse(userAgent string) models.UserAgentInfo {
+	p.mu.RLock()
+	info, ok := p.cache[userAgent]
+	p.mu.RUnlock()
+	if ok {
+		return info
+	}
+
+	info = parseUserAgent(userAgent)
+
+	p.mu.Lock()
+	// Start over rather than track recency; the hot set refills within moments
+	if len(p.cache) >= p.cacheSize {
+		p.cache = make(map[string]models.UserAgentInfo)
+	}
+	p.cache[userAgent] = info
+	p.mu.Unlock()
+
+	return info
+}
+
+// parseUserAgent parses a user agent string without caching
+func parseUserAgent(userAgent string) models.UserAgentInfo {
+	ua := useragent.New(userAgent)
+	browser, browserVersion := ua.Browser()
+	os := ua.OSInfo()
+
+	info := models.UserAgentInfo{
+		Browser:        browser,
+		BrowserVersion: browserVersion,
+		OS:             os.Name,
+		OSVersion:      os.Version,
+		DeviceModel:    ua.Model(),
+		IsBot:          ua.Bot(),
+	}
+	info.DeviceType = deviceType(ua, userAgent, info.IsBot)
+
+	// iPads report "CPU OS", which the parser leaves as a bare "OS"
+	if info.OS == "OS" && info.DeviceModel == "iPad" {
+		info.OS = "iPadOS"
+	}
+
+	return info
+}
+
+// deviceType classifies the device; tablets are told apart by their well-known tokens
+func deviceType(ua *useragent.UserAgent, userAgent string, bot bool) string {
+	switch {
+	case bot:
+		return DeviceBot
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(strings.Contains(userAgent, "Android") && !strings.Contains(userAgent, "Mobile")):
+		return DeviceTablet
+	case ua.Mobile():
+		return DeviceMobile
+	case ua.OS() == "" && ua.Platform() == "":
+		return DeviceUnknown
+	default:
+		return DeviceDesktop
+	}
+}
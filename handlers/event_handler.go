@@ -1,11 +1,23 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"ingestion-service/auth"
+	"ingestion-service/dedup"
+	"ingestion-service/enrichment"
+	"ingestion-service/metrics"
 	"ingestion-service/models"
+	"ingestion-service/ratelimit"
+	"ingestion-service/schema"
 	"ingestion-service/services"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,17 +25,146 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// maxBatchSize is the maximum number of events accepted in a single batch request
+	maxBatchSize = 500
+
+	// maxStreamLineBytes is the maximum size of a single NDJSON line
+	maxStreamLineBytes = 1024 * 1024
+
+	// maxStreamErrors is the maximum number of line errors reported for a stream
+	maxStreamErrors = 100
+
+	// deliveryConfirmationHeader lets a request opt in or out of waiting for the broker ack
+	deliveryConfirmationHeader = "X-Delivery-Confirmation"
+
+	// tenantHeader identifies the tenant the events belong to
+	tenantHeader = "X-Tenant-ID"
+
+	// idempotencyKeyHeader makes retries idempotent for events without an event_id
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// maxPublishAttempts is how often a failed publish is tried before the event is rejected
+	maxPublishAttempts = 3
+)
+
+// requestMetadata carries request-level attributes stamped onto every event of a request
+type requestMetadata struct {
+	requestID      string
+	projectID      string
+	tenantID       string
+	idempotencyKey string
+	userAgent      string
+	clientIP       string
+	trace          *models.TraceContext
+
+	// confirmDelivery makes each event of the request wait for the sink's acknowledgement
+	confirmDelivery bool
+	// sinkFailed is set once an event of a batch or stream failed to publish, so the
+	// remaining events get a single attempt instead of each waiting out the backoff
+	sinkFailed bool
+}
+
+// newRequestMetadata captures the authenticated project, the tenant and W3C trace
+// context from the request. Once a write key identifies the request, the tenant comes
+// only from the key, so a key cannot stamp events with another tenant's ID; the tenant
+// header is only read from unauthenticated requests.
+func newRequestMetadata(c *gin.Context, requestID string) requestMetadata {
+	metadata := requestMetadata{
+		requestID:      requestID,
+		idempotencyKey: c.GetHeader(idempotencyKeyHeader),
+		userAgent:      c.Request.UserAgent(),
+		clientIP:       c.ClientIP(),
+	}
+
+	if identity, ok := auth.IdentityFromContext(c.Request.Context()); ok {
+		metadata.projectID = identity.ProjectID
+		metadata.tenantID = identity.TenantID
+	} else {
+		metadata.tenantID = c.GetHeader(tenantHeader)
+	}
+
+	if traceParent := c.GetHeader("traceparent"); traceParent != "" {
+		metadata.trace = &models.TraceContext{
+			TraceParent: traceParent,
+			TraceState:  c.GetHeader("tracestate"),
+		}
+	}
+
+	return metadata
+}
+
+// apply stamps the request metadata onto an enriched event. The request's
+// User-Agent header stands in when the payload omits client_info.user_agent.
+func (m requestMetadata) apply(event *models.EnrichedEvent) {
+	event.ProjectID = m.projectID
+	event.ClientIP = m.clientIP
+	event.TenantID = m.tenantID
+	event.TraceContext = m.trace
+	if event.ClientInfo.UserAgent == "" {
+		event.ClientInfo.UserAgent = m.userAgent
+	}
+}
+
+// dedupKey returns the idempotency key of the event at index within the request, scoped
+// to the project, or to the client IP when no write key identifies one. The client's
+// event_id wins; otherwise the Idempotency-Key header is combined with the index so each
+// event of a retried batch maps to the same key. It returns "" when the event has neither.
+func (m requestMetadata) dedupKey(eventID string, index int) string {
+	scope := "project:" + m.projectID
+	if m.projectID == "" {
+		// Without a project, clients could otherwise suppress each other's events; the
+		// price is that a retry from another IP is not recognized as a repeat
+		scope = "ip:" + m.clientIP
+	}
+
+	switch {
+	case eventID != "":
+		return scope + "/event/" + eventID
+	case m.idempotencyKey != "":
+		return scope + "/key/" + m.idempotencyKey + "/" + strconv.Itoa(index)
+	default:
+		return ""
+	}
+}
+
+// EventHandlerConfig holds event handler settings
+type EventHandlerConfig struct {
+	// ConfirmDelivery makes every event wait for the sink's acknowledgement unless the request opts out
+	ConfirmDelivery bool
+
+	// Schemas validates event payloads and their event_data
+	Schemas *schema.Registry
+
+	// RateLimiter limits events per user_id; nil disables the limit
+	RateLimiter *ratelimit.Limiter
+
+	// Enrichment runs between validation and publish; nil publishes events as validated
+	Enrichment *enrichment.Pipeline
+
+	// GeoIP reports the loaded databases in stats; lookups run as a pipeline step
+	GeoIP *enrichment.GeoIPResolver
+
+	// Privacy filters dead-lettered payloads; event rules run as a pipeline step
+	Privacy *enrichment.PrivacyFilter
+
+	// Dedup acknowledges events repeating an idempotency key without republishing them; nil disables it
+	Dedup *dedup.Cache
+}
+
 // EventHandler handles event-related HTTP requests
 type EventHandler struct {
-	kafkaService *services.KafkaService
-	logger       *zap.Logger
+	sink   services.EventSink
+	config EventHandlerConfig
+	logger *zap.Logger
 }
 
-// NewEventHandler creates a new event handler
-func NewEventHandler(kafkaService *services.KafkaService, logger *zap.Logger) *EventHandler {
+// NewEventHandler creates a new event handler publishing to sink
+func NewEventHandler(sink services.EventSink, config EventHandlerConfig, logger *zap.Logger) *EventHandler {
 	return &EventHandler{
-		kafkaService: kafkaService,
-		logger:       logger,
+		sink:   sink,
+		config: config,
+		logger: logger,
 	}
 }
 
@@ -52,24 +193,102 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 		return
 	}
 
+	metadata := newRequestMetadata(c, requestID)
+	if result, allowed := h.allowUser(ctx, metadata, event.UserID); !allowed {
+		ratelimit.WriteHeaders(c.Writer.Header(), result)
+		metrics.RecordEventRejected(event.EventType, "RATE_LIMITED")
+		c.JSON(http.StatusTooManyRequests, models.NewErrorResponse(
+			"RATE_LIMITED",
+			"Too many events for this user_id, retry after the Retry-After interval",
+			requestID,
+		))
+		return
+	}
+
 	// Enrich the event with metadata
-	enrichedEvent := models.EnrichEvent(event, requestID)
+	enrichedEvent, err := h.enrichEvent(ctx, event, metadata)
+	if err != nil {
+		h.logger.Error("Event enrichment failed",
+			zap.String("request_id", requestID),
+			zap.Error(err),
+		)
+		metrics.RecordEventRejected(event.EventType, "ENRICHMENT_FAILED")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"ENRICHMENT_FAILED",
+			"Failed to enrich event",
+			requestID,
+		))
+		return
+	}
 
-	// Publish event to Kafka
-	if err := h.publishEventToKafka(ctx, enrichedEvent, requestID); err != nil {
-		h.logger.Error("Failed to publish event to Kafka",
+	// Acknowledge repeats of an idempotency key without publishing them again
+	dedupKey := metadata.dedupKey(event.EventID, 0)
+	switch originalID, status := h.claimDedupKey(dedupKey, enrichedEvent.EventID); status {
+	case dedup.Duplicate:
+		h.logger.Info("Duplicate event acknowledged",
+			zap.String("request_id", requestID),
+			zap.String("event_id", originalID),
+		)
+		metrics.RecordEventDuplicate(enrichedEvent.EventType)
+		response := models.NewEventResponse(requestID)
+		response.EventID = originalID
+		response.Duplicate = true
+		c.JSON(http.StatusOK, response)
+		return
+	case dedup.InFlight:
+		// The first event may still fail, so it is too early to acknowledge this one
+		h.logger.Info("Duplicate of an event still being published",
+			zap.String("request_id", requestID),
+			zap.String("event_id", originalID),
+		)
+		metrics.RecordEventRejected(enrichedEvent.EventType, "DUPLICATE_IN_FLIGHT")
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"DUPLICATE_IN_FLIGHT",
+			"An event with the same idempotency key is still being published, retry shortly",
+			requestID,
+		))
+		return
+	}
+
+	// Publish and wait for the broker ack when delivery confirmation is requested
+	var delivery *models.DeliveryInfo
+	if h.wantsDeliveryConfirmation(c) {
+		delivery, err = h.publishEventSync(ctx, enrichedEvent)
+		if err != nil {
+			h.logger.Error("Delivery confirmation failed",
+				zap.String("request_id", requestID),
+				zap.String("event_id", enrichedEvent.EventID),
+				zap.Error(err),
+			)
+			h.releaseDedupKey(dedupKey)
+			metrics.RecordEventRejected(enrichedEvent.EventType, "SINK_DELIVERY_FAILED")
+			c.JSON(http.StatusBadGateway, models.NewErrorResponse(
+				"SINK_DELIVERY_FAILED",
+				"Event was not acknowledged by the sink",
+				requestID,
+			))
+			return
+		}
+	} else if err := h.publishEvent(ctx, enrichedEvent, requestID, maxPublishAttempts); err != nil {
+		h.logger.Error("Failed to publish event",
 			zap.String("request_id", requestID),
 			zap.String("event_id", enrichedEvent.EventID),
 			zap.Error(err),
 		)
+		h.releaseDedupKey(dedupKey)
+		metrics.RecordEventRejected(enrichedEvent.EventType, "SINK_ERROR")
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
-			"KAFKA_ERROR",
+			"SINK_ERROR",
 			"Failed to process event",
 			requestID,
 		))
 		return
 	}
 
+	h.confirmDedupKey(dedupKey)
+	metrics.RecordEventAccepted(enrichedEvent.EventType)
+
 	// Calculate processing time
 	processingTime := time.Since(startTime)
 	enrichedEvent.ProcessingInfo.ProcessingMs = processingTime.Milliseconds()
@@ -77,6 +296,7 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 	// Create response
 	response := models.NewEventResponse(requestID)
 	response.EventID = enrichedEvent.EventID
+	response.Delivery = delivery
 
 	h.logger.Info("Event processed successfully",
 		zap.String("request_id", requestID),
@@ -90,34 +310,394 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// wantsDeliveryConfirmation reports whether the request should wait for the sink's acknowledgement
+func (h *EventHandler) wantsDeliveryConfirmation(c *gin.Context) bool {
+	if value := c.GetHeader(deliveryConfirmationHeader); value != "" {
+		if confirm, err := strconv.ParseBool(value); err == nil {
+			return confirm
+		}
+	}
+	return h.config.ConfirmDelivery
+}
+
+// publishEventSync publishes an event and waits for the sink's acknowledgement. Events no
+// required sink selects are acknowledged without a delivery report.
+func (h *EventHandler) publishEventSync(ctx context.Context, event models.EnrichedEvent) (*models.DeliveryInfo, error) {
+	report, err := h.sink.PublishEventSync(ctx, event)
+	if err != nil || report.Topic == "" {
+		return nil, err
+	}
+	return &models.DeliveryInfo{
+		Topic:     report.Topic,
+		Partition: report.Partition,
+		Offset:    report.Offset,
+		ID:        report.ID,
+	}, nil
+}
+
+// TrackBatch handles batch event tracking requests
+func (h *EventHandler) TrackBatch(c *gin.Context) {
+	startTime := time.Now()
+	requestID := uuid.New().String()
+
+	// Add request ID to context for logging
+	ctx := context.WithValue(c.Request.Context(), "request_id", requestID)
+	c.Request = c.Request.WithContext(ctx)
+
+	h.logger.Info("Processing batch tracking request",
+		zap.String("request_id", requestID),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+	)
+
+	// Decode the batch as raw messages so each event can be rejected on its own
+	body, err := c.GetRawData()
+	if err != nil {
+		h.rejectUnreadableBody(c, err, requestID)
+		return
+	}
+
+	var rawEvents []json.RawMessage
+	if err := json.Unmarshal(body, &rawEvents); err != nil {
+		h.logger.Error("Failed to parse batch payload",
+			zap.String("request_id", requestID),
+			zap.Error(err),
+		)
+		h.publishDeadLetter(ctx, body, "INVALID_JSON", err.Error(), requestID)
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"INVALID_JSON",
+			"Batch payload must be a JSON array of events",
+			requestID,
+		))
+		return
+	}
+
+	if len(rawEvents) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"EMPTY_BATCH",
+			"Batch must contain at least one event",
+			requestID,
+		))
+		return
+	}
+
+	if len(rawEvents) > maxBatchSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"BATCH_TOO_LARGE",
+			fmt.Sprintf("Batch must not contain more than %d events", maxBatchSize),
+			requestID,
+		))
+		return
+	}
+
+	metadata := newRequestMetadata(c, requestID)
+	metadata.confirmDelivery = h.wantsDeliveryConfirmation(c)
+	results := make([]models.BatchEventResult, len(rawEvents))
+	for i, raw := range rawEvents {
+		results[i] = h.processRawEvent(ctx, raw, i, i > 0, metadata)
+		if results[i].Code == "SINK_ERROR" {
+			metadata.sinkFailed = true
+		}
+	}
+
+	response := models.NewBatchEventResponse(requestID, results)
+
+	h.logger.Info("Batch processed",
+		zap.String("request_id", requestID),
+		zap.Int("events", len(rawEvents)),
+		zap.Int("accepted", response.Accepted),
+		zap.Int("rejected", response.Rejected),
+		zap.Duration("processing_time", time.Since(startTime)),
+	)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StreamEvents handles newline-delimited JSON event streams
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	startTime := time.Now()
+	requestID := uuid.New().String()
+
+	// Add request ID to context for logging
+	ctx := context.WithValue(c.Request.Context(), "request_id", requestID)
+	c.Request = c.Request.WithContext(ctx)
+
+	h.logger.Info("Processing event stream request",
+		zap.String("request_id", requestID),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+	)
+
+	if !strings.Contains(c.GetHeader("Content-Type"), "application/x-ndjson") {
+		c.JSON(http.StatusUnsupportedMediaType, models.NewErrorResponse(
+			"INVALID_CONTENT_TYPE",
+			"Content-Type must be application/x-ndjson",
+			requestID,
+		))
+		return
+	}
+
+	// Read the body line by line so the stream is never buffered in full
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineBytes)
+
+	metadata := newRequestMetadata(c, requestID)
+	metadata.confirmDelivery = h.wantsDeliveryConfirmation(c)
+	accepted, rejected, duplicates := 0, 0, 0
+	var lineErrors []models.StreamLineError
+	line, events := 0, 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		events++
+
+		result := h.processRawEvent(ctx, raw, line, events > 1, metadata)
+		if result.Code == "SINK_ERROR" {
+			metadata.sinkFailed = true
+		}
+		if result.Status == "accepted" {
+			accepted++
+			if result.Duplicate {
+				duplicates++
+			}
+			continue
+		}
+
+		rejected++
+		if len(lineErrors) < maxStreamErrors {
+			lineErrors = append(lineErrors, models.StreamLineError{
+				Line:    line,
+				Code:    result.Code,
+				Reason:  result.Reason,
+				Details: result.Details,
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		code, message := "STREAM_READ_ERROR", "Failed to read event stream"
+		if errors.Is(err, bufio.ErrTooLong) {
+			code = "LINE_TOO_LARGE"
+			message = fmt.Sprintf("Line %d exceeds the maximum size of %d bytes", line+1, maxStreamLineBytes)
+		}
+
+		h.logger.Error("Event stream aborted",
+			zap.String("request_id", requestID),
+			zap.Int("line", line+1),
+			zap.Int("accepted", accepted),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			code,
+			fmt.Sprintf("%s after %d accepted events", message, accepted),
+			requestID,
+		))
+		return
+	}
+
+	response := models.NewStreamEventResponse(requestID, accepted, lineErrors, rejected)
+	response.Duplicates = duplicates
+
+	h.logger.Info("Event stream processed",
+		zap.String("request_id", requestID),
+		zap.Int("lines", line),
+		zap.Int("accepted", accepted),
+		zap.Int("rejected", rejected),
+		zap.Duration("processing_time", time.Since(startTime)),
+	)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// processRawEvent decodes, validates and publishes a single raw event. With charge, the
+// event takes its own token from the request's IP and write key rate limits; the first
+// event of a request is paid for by the request's token.
+func (h *EventHandler) processRawEvent(ctx context.Context, raw json.RawMessage, index int, charge bool, metadata requestMetadata) models.BatchEventResult {
+	requestID := metadata.requestID
+	result := models.BatchEventResult{Index: index}
+
+	if charge {
+		if reason, allowed := h.chargeEvent(ctx, metadata); !allowed {
+			metrics.RecordEventRejected("", "RATE_LIMITED")
+			result.Status = "rejected"
+			result.Code = "RATE_LIMITED"
+			result.Reason = reason
+			return result
+		}
+	}
+
+	var event models.EventPayload
+	if err := json.Unmarshal(raw, &event); err != nil {
+		metrics.RecordEventRejected("", "INVALID_JSON")
+		h.publishDeadLetter(ctx, raw, "INVALID_JSON", err.Error(), requestID)
+		result.Status = "rejected"
+		result.Code = "INVALID_JSON"
+		result.Reason = "Invalid JSON payload"
+		result.Details = fieldErrors(err)
+		return result
+	}
+
+	if err := h.validateEvent(&event); err != nil {
+		h.logger.Debug("Batch event validation failed",
+			zap.String("request_id", requestID),
+			zap.Int("index", index),
+			zap.Error(err),
+		)
+		metrics.RecordEventRejected(event.EventType, "VALIDATION_ERROR")
+		h.publishDeadLetter(ctx, raw, "VALIDATION_ERROR", err.Error(), requestID)
+		result.Status = "rejected"
+		result.Code = "VALIDATION_ERROR"
+		result.Reason = validationMessage(err)
+		result.Details = fieldErrors(err)
+		return result
+	}
+
+	if _, allowed := h.allowUser(ctx, metadata, event.UserID); !allowed {
+		metrics.RecordEventRejected(event.EventType, "RATE_LIMITED")
+		result.Status = "rejected"
+		result.Code = "RATE_LIMITED"
+		result.Reason = "Too many events for this user_id"
+		return result
+	}
+
+	enrichedEvent, err := h.enrichEvent(ctx, event, metadata)
+	if err != nil {
+		h.logger.Error("Batch event enrichment failed",
+			zap.String("request_id", requestID),
+			zap.Int("index", index),
+			zap.Error(err),
+		)
+		metrics.RecordEventRejected(event.EventType, "ENRICHMENT_FAILED")
+		result.Status = "rejected"
+		result.Code = "ENRICHMENT_FAILED"
+		result.Reason = "Failed to enrich event"
+		return result
+	}
+
+	dedupKey := metadata.dedupKey(event.EventID, index)
+	switch originalID, status := h.claimDedupKey(dedupKey, enrichedEvent.EventID); status {
+	case dedup.Duplicate:
+		metrics.RecordEventDuplicate(enrichedEvent.EventType)
+		result.Status = "accepted"
+		result.EventID = originalID
+		result.Duplicate = true
+		return result
+	case dedup.InFlight:
+		metrics.RecordEventRejected(enrichedEvent.EventType, "DUPLICATE_IN_FLIGHT")
+		result.Status = "rejected"
+		result.Code = "DUPLICATE_IN_FLIGHT"
+		result.Reason = "An event with the same idempotency key is still being published, retry shortly"
+		return result
+	}
+
+	if metadata.confirmDelivery {
+		delivery, err := h.publishEventSync(ctx, enrichedEvent)
+		if err != nil {
+			h.logger.Error("Batch event delivery confirmation failed",
+				zap.String("request_id", requestID),
+				zap.String("event_id", enrichedEvent.EventID),
+				zap.Int("index", index),
+				zap.Error(err),
+			)
+			h.releaseDedupKey(dedupKey)
+			metrics.RecordEventRejected(enrichedEvent.EventType, "SINK_DELIVERY_FAILED")
+			result.Status = "rejected"
+			result.Code = "SINK_DELIVERY_FAILED"
+			result.Reason = "Event was not acknowledged by the sink"
+			return result
+		}
+		result.Delivery = delivery
+	} else if err := h.publishEvent(ctx, enrichedEvent, requestID, publishAttempts(metadata)); err != nil {
+		h.logger.Error("Failed to publish batch event",
+			zap.String("request_id", requestID),
+			zap.String("event_id", enrichedEvent.EventID),
+			zap.Int("index", index),
+			zap.Error(err),
+		)
+		h.releaseDedupKey(dedupKey)
+		metrics.RecordEventRejected(enrichedEvent.EventType, "SINK_ERROR")
+		result.Status = "rejected"
+		result.Code = "SINK_ERROR"
+		result.Reason = "Failed to process event"
+		return result
+	}
+
+	h.confirmDedupKey(dedupKey)
+	metrics.RecordEventAccepted(enrichedEvent.EventType)
+	result.Status = "accepted"
+	result.EventID = enrichedEvent.EventID
+	return result
+}
+
+// rejectUnreadableBody responds to a request whose body could not be read, telling
+// oversized bodies apart from broken connections
+func (h *EventHandler) rejectUnreadableBody(c *gin.Context, err error, requestID string) {
+	h.logger.Error("Failed to read request body",
+		zap.String("request_id", requestID),
+		zap.Error(err),
+	)
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"REQUEST_TOO_LARGE",
+			"Request body too large",
+			requestID,
+		))
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		"INVALID_BODY",
+		"Failed to read request body",
+		requestID,
+	))
+}
+
 // parseAndValidateEvent parses and validates the incoming event
 func (h *EventHandler) parseAndValidateEvent(c *gin.Context, requestID string) (models.EventPayload, error) {
 	var event models.EventPayload
 
+	// Read the raw body so rejected payloads can be dead-lettered as received
+	body, err := c.GetRawData()
+	if err != nil {
+		h.rejectUnreadableBody(c, err, requestID)
+		return models.EventPayload{}, err
+	}
+
 	// Parse JSON payload
-	if err := c.ShouldBindJSON(&event); err != nil {
+	if err := json.Unmarshal(body, &event); err != nil {
 		h.logger.Error("Failed to parse JSON payload",
 			zap.String("request_id", requestID),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		metrics.RecordEventRejected("", "INVALID_JSON")
+		h.publishDeadLetter(c.Request.Context(), body, "INVALID_JSON", err.Error(), requestID)
+		c.JSON(http.StatusBadRequest, models.NewValidationErrorResponse(
 			"INVALID_JSON",
 			"Invalid JSON payload",
 			requestID,
+			fieldErrors(err),
 		))
 		return models.EventPayload{}, err
 	}
 
 	// Validate required fields
-	if err := h.validateEvent(event); err != nil {
+	if err := h.validateEvent(&event); err != nil {
 		h.logger.Error("Event validation failed",
 			zap.String("request_id", requestID),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		metrics.RecordEventRejected(event.EventType, "VALIDATION_ERROR")
+		h.publishDeadLetter(c.Request.Context(), body, "VALIDATION_ERROR", err.Error(), requestID)
+		c.JSON(http.StatusBadRequest, models.NewValidationErrorResponse(
 			"VALIDATION_ERROR",
-			err.Error(),
+			validationMessage(err),
 			requestID,
+			fieldErrors(err),
 		))
 		return models.EventPayload{}, err
 	}
@@ -125,96 +705,309 @@ func (h *EventHandler) parseAndValidateEvent(c *gin.Context, requestID string) (
 	return event, nil
 }
 
-// validateEvent validates the event payload
-func (h *EventHandler) validateEvent(event models.EventPayload) error {
-	if event.EventType == "" {
-		return fmt.Errorf("event_type is required")
+// validateEvent validates the event payload against the schema registry and
+// records the schema version it was validated against
+func (h *EventHandler) validateEvent(event *models.EventPayload) error {
+	version, err := h.config.Schemas.Validate(*event)
+	if err != nil {
+		return err
+	}
+
+	event.SchemaVersion = version
+	return nil
+}
+
+// enrichEvent builds the enriched event from a validated payload and the request
+// metadata, then runs the enrichment pipeline. It fails only when a step with the
+// fail policy fails.
+func (h *EventHandler) enrichEvent(ctx context.Context, event models.EventPayload, metadata requestMetadata) (models.EnrichedEvent, error) {
+	enrichedEvent := models.EnrichEvent(event, metadata.requestID)
+	metadata.apply(&enrichedEvent)
+
+	if h.config.Enrichment != nil {
+		if err := h.config.Enrichment.Run(ctx, &enrichedEvent); err != nil {
+			return models.EnrichedEvent{}, err
+		}
+	}
+
+	return enrichedEvent, nil
+}
+
+// allowUser applies the per-user rate limit within the caller's project. Backend
+// failures are logged and the event is let through.
+func (h *EventHandler) allowUser(ctx context.Context, metadata requestMetadata, userID string) (ratelimit.Result, bool) {
+	if h.config.RateLimiter == nil {
+		return ratelimit.Result{}, true
+	}
+
+	result, err := h.config.RateLimiter.Allow(ctx, "user", metadata.projectID+"/"+userID)
+	if err != nil {
+		h.logger.Warn("User rate limit check failed",
+			zap.String("request_id", metadata.requestID),
+			zap.Error(err),
+		)
+		return ratelimit.Result{}, true
 	}
 
-	if event.UserID == "" {
-		return fmt.Errorf("user_id is required")
+	if !result.Allowed {
+		metrics.RateLimited.WithLabelValues("user").Inc()
+		h.logger.Warn("Event rate limited",
+			zap.String("request_id", metadata.requestID),
+			zap.String("user_id", userID),
+		)
 	}
+	return result, result.Allowed
+}
 
-	if event.SessionID == "" {
-		return fmt.Errorf("session_id is required")
+// chargeEvent debits a further event of the request from the IP and write key buckets that
+// admitted it, returning the rejection reason when one is exhausted. Backend failures are
+// logged and the event is let through.
+func (h *EventHandler) chargeEvent(ctx context.Context, metadata requestMetadata) (string, bool) {
+	dimension, err := ratelimit.ChargeEvents(ctx, 1)
+	if err != nil {
+		h.logger.Warn("Rate limit charge failed",
+			zap.String("request_id", metadata.requestID),
+			zap.Error(err),
+		)
+	}
+	if dimension == "" {
+		return "", true
 	}
 
-	if event.PageURL == "" {
-		return fmt.Errorf("page_url is required")
+	metrics.RateLimited.WithLabelValues(dimension).Inc()
+	h.logger.Warn("Event rate limited",
+		zap.String("request_id", metadata.requestID),
+		zap.String("dimension", dimension),
+	)
+	if dimension == "key" {
+		return "Too many events for this write key", false
 	}
+	return "Too many events from this client IP", false
+}
 
-	// Validate timestamp format if provided
-	if event.Timestamp != "" {
-		if _, err := time.Parse(time.RFC3339, event.Timestamp); err != nil {
-			return fmt.Errorf("invalid timestamp format, expected RFC3339")
+// claimDedupKey claims the idempotency key for the event. When the key was already seen
+// within the dedup window it returns the event ID of the first claim and whether that
+// event was published or is still in flight.
+func (h *EventHandler) claimDedupKey(key, eventID string) (string, dedup.Status) {
+	if h.config.Dedup == nil || key == "" {
+		return eventID, dedup.Claimed
+	}
+	return h.config.Dedup.Claim(key, eventID)
+}
+
+// confirmDedupKey marks a claimed key as published, so later repeats are acknowledged
+func (h *EventHandler) confirmDedupKey(key string) {
+	if h.config.Dedup != nil && key != "" {
+		h.config.Dedup.Confirm(key)
+	}
+}
+
+// releaseDedupKey forgets a claimed key after a failed publish so the client can retry
+func (h *EventHandler) releaseDedupKey(key string) {
+	if h.config.Dedup != nil && key != "" {
+		h.config.Dedup.Release(key)
+	}
+}
+
+// fieldErrors converts a decoding or validation error into per-field details
+func fieldErrors(err error) []models.FieldError {
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) {
+		details := make([]models.FieldError, 0, len(validationErr.Violations))
+		for _, violation := range validationErr.Violations {
+			details = append(details, models.FieldError{
+				Field:   violation.Pointer,
+				Code:    violation.Code,
+				Message: violation.Message,
+			})
 		}
+		return details
+	}
+
+	// A well-formed document with a value of the wrong type still has a field to point at
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		field := "/" + strings.ReplaceAll(typeErr.Field, ".", "/")
+		return []models.FieldError{{
+			Field:   field,
+			Code:    "INVALID_TYPE",
+			Message: fmt.Sprintf("%s: expected %s, but got %s", typeErr.Field, typeErr.Type, typeErr.Value),
+		}}
 	}
 
 	return nil
 }
 
-// publishEventToKafka publishes the enriched event to Kafka
-func (h *EventHandler) publishEventToKafka(ctx context.Context, event models.EnrichedEvent, requestID string) error {
-	h.logger.Debug("Publishing event to Kafka",
+// validationMessage summarises a validation error for the top-level message
+func validationMessage(err error) string {
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) && len(validationErr.Violations) > 1 {
+		return fmt.Sprintf("%d validation errors, first: %s", len(validationErr.Violations), err.Error())
+	}
+	return err.Error()
+}
+
+// publishEvent publishes the enriched event to the sink, trying up to attempts times
+func (h *EventHandler) publishEvent(ctx context.Context, event models.EnrichedEvent, requestID string, attempts int) error {
+	h.logger.Debug("Publishing event",
 		zap.String("request_id", requestID),
 		zap.String("event_id", event.EventID),
-		zap.String("topic", "user-activity-events"),
+		zap.String("sink", h.sink.Name()),
 	)
 
-	// Publish to Kafka with retry logic
-	maxRetries := 3
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := h.kafkaService.PublishEvent(ctx, event, event.EventID)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err := h.sink.PublishEvent(ctx, event)
 		if err == nil {
 			return nil
 		}
+		if errors.Is(err, services.ErrRetriesExhausted) {
+			// The sink retried the parts that failed; retrying here would republish the rest
+			return err
+		}
 
-		h.logger.Warn("Kafka publish attempt failed",
+		h.logger.Warn("Publish attempt failed",
 			zap.String("request_id", requestID),
 			zap.String("event_id", event.EventID),
 			zap.Int("attempt", attempt),
-			zap.Int("max_retries", maxRetries),
+			zap.Int("max_attempts", attempts),
 			zap.Error(err),
 		)
 
-		if attempt < maxRetries {
-			// Exponential backoff
-			backoffTime := time.Duration(attempt*attempt) * time.Millisecond * 100
-			time.Sleep(backoffTime)
+		if attempt == attempts {
+			break
+		}
+
+		// Exponential backoff, cut short when the client goes away
+		select {
+		case <-time.After(time.Duration(attempt*attempt) * 100 * time.Millisecond):
+		case <-ctx.Done():
+			return fmt.Errorf("publish abandoned after %d attempts: %w", attempt, ctx.Err())
 		}
 	}
 
-	return fmt.Errorf("failed to publish event to Kafka after %d attempts", maxRetries)
+	return fmt.Errorf("failed to publish event after %d attempts", attempts)
+}
+
+// publishAttempts returns how often an event of a batch or stream may be tried: once the
+// sink failed an event of the request, later events are not retried
+func publishAttempts(metadata requestMetadata) int {
+	if metadata.sinkFailed {
+		return 1
+	}
+	return maxPublishAttempts
+}
+
+// publishDeadLetter sends a rejected payload to the sink's dead letters, if it keeps them
+func (h *EventHandler) publishDeadLetter(ctx context.Context, raw []byte, code, message, requestID string) {
+	if !h.sink.DeadLetterEnabled() {
+		return
+	}
+
+	// Payloads that parse get every privacy rule; the rest only the text scrubbers
+	if h.config.Privacy != nil {
+		raw = h.config.Privacy.FilterPayload(raw)
+	}
+
+	envelope := models.NewDeadLetterEnvelope(raw, code, message, requestID, h.sink.MaxDeadLetterPayloadBytes())
+	if err := h.sink.PublishDeadLetter(ctx, envelope); err != nil {
+		h.logger.Error("Failed to publish dead letter",
+			zap.String("request_id", requestID),
+			zap.String("error_code", code),
+			zap.Error(err),
+		)
+	}
 }
 
 // HealthCheck handles health check requests
 func (h *EventHandler) HealthCheck(c *gin.Context) {
 	requestID := uuid.New().String()
 
-	// Check Kafka service health
-	kafkaHealth := "healthy"
-	if err := h.kafkaService.HealthCheck(); err != nil {
-		kafkaHealth = "unhealthy"
-		h.logger.Error("Kafka health check failed",
+	// Check sink health
+	sinkHealth := "healthy"
+	if err := h.sink.HealthCheck(); err != nil {
+		sinkHealth = "unhealthy"
+		h.logger.Error("Sink health check failed",
+			zap.String("sink", h.sink.Name()),
 			zap.String("request_id", requestID),
 			zap.Error(err),
 		)
 	}
 
 	response := models.NewHealthResponse()
-	response.Status = kafkaHealth
+	response.Status = sinkHealth
+
+	statusCode := http.StatusOK
+	if sinkHealth != "healthy" {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	c.JSON(statusCode, response)
+}
 
+// Liveness reports that the process is running and able to serve requests. It never
+// consults the sink, so broker outages fail readiness instead of restarting the pod.
+func (h *EventHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.NewHealthResponse())
+}
+
+// Readiness reports whether the service can currently deliver events to the sink
+func (h *EventHandler) Readiness(c *gin.Context) {
+	checks, ready := h.sink.ReadinessCheck(c.Request.Context())
+
+	response := models.NewHealthResponse()
+	response.Checks = checks
+
+	if !ready {
+		response.Status = "not_ready"
+		h.logger.Warn("Readiness check failed", zap.Any("checks", checks))
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	response.Status = "ready"
 	c.JSON(http.StatusOK, response)
 }
 
 // GetStats returns service statistics
 func (h *EventHandler) GetStats(c *gin.Context) {
 	stats := map[string]interface{}{
-		"service":   "ingestion-service",
-		"version":   "1.0.0",
-		"kafka":     h.kafkaService.GetStats(),
+		"service": "ingestion-service",
+		"version": "1.0.0",
+		"schemas": map[string]interface{}{
+			"event_types":          h.config.Schemas.EventTypes(),
+			"unknown_event_policy": h.config.Schemas.Policy(),
+		},
 		"timestamp": time.Now().UTC(),
 	}
+	stats[h.sink.Name()] = h.sink.GetStats()
+
+	if h.config.Enrichment != nil {
+		steps := make([]map[string]interface{}, 0)
+		for _, step := range h.config.Enrichment.Steps() {
+			steps = append(steps, map[string]interface{}{
+				"name":     step.Name,
+				"timeout":  step.Timeout.String(),
+				"on_error": step.OnError,
+			})
+		}
+		stats["enrichment_steps"] = steps
+	}
+
+	if h.config.GeoIP != nil {
+		stats["geoip"] = h.config.GeoIP.Stats()
+	}
+
+	if h.config.Privacy != nil {
+		stats["privacy_rules"] = h.config.Privacy.Rules()
+	}
+
+	if h.config.Dedup != nil {
+		entries, evicted := h.config.Dedup.Stats()
+		stats["dedup"] = map[string]interface{}{
+			"entries": entries,
+			"evicted": evicted,
+		}
+	}
 
 	c.JSON(http.StatusOK, stats)
 }
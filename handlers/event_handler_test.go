@@ -0,0 +1,496 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ingestion-service/auth"
+	"ingestion-service/dedup"
+	"ingestion-service/middleware"
+	"ingestion-service/models"
+	"ingestion-service/ratelimit"
+	"ingestion-service/schema"
+	"ingestion-service/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// testSink is a memory sink that can be made to fail or to hold publishes until released
+type testSink struct {
+	*services.MemorySink
+	fail     atomic.Bool
+	attempts atomic.Int64
+	entered  chan struct{}
+	release  chan struct{}
+}
+
+func newTestSink() *testSink {
+	return &testSink{MemorySink: services.NewMemorySink(100, zap.NewNop())}
+}
+
+func (s *testSink) wait() error {
+	s.attempts.Add(1)
+	if s.release != nil {
+		s.entered <- struct{}{}
+		<-s.release
+	}
+	if s.fail.Load() {
+		return errors.New("sink unavailable")
+	}
+	return nil
+}
+
+func (s *testSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	if err := s.wait(); err != nil {
+		return err
+	}
+	return s.MemorySink.PublishEvent(ctx, event)
+}
+
+func (s *testSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (services.DeliveryReport, error) {
+	if err := s.wait(); err != nil {
+		return services.DeliveryReport{}, err
+	}
+	return s.MemorySink.PublishEventSync(ctx, event)
+}
+
+func newTestRouter(t *testing.T, sink services.EventSink, middleware ...gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	schemas, err := schema.NewRegistry("", schema.PolicyAllow)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	handler := NewEventHandler(sink, EventHandlerConfig{
+		Schemas: schemas,
+		Dedup:   dedup.NewCache(time.Minute, 100),
+	}, zap.NewNop())
+
+	router := gin.New()
+	router.Use(middleware...)
+	router.POST("/track", handler.TrackEvent)
+	router.POST("/batch", handler.TrackBatch)
+	return router
+}
+
+func post(router *gin.Engine, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func testEvent(eventID string) string {
+	event := map[string]interface{}{
+		"event_type": "page_view",
+		"user_id":    "user-1",
+		"session_id": "session-1",
+		"page_url":   "https://example.com/",
+	}
+	if eventID != "" {
+		event["event_id"] = eventID
+	}
+	body, _ := json.Marshal(event)
+	return string(body)
+}
+
+func TestTrackEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		headers     map[string]string
+		wantStatus  int
+		wantCode    string
+		wantEvents  int
+		deadLetters int
+	}{
+		{
+			name:       "valid event",
+			body:       testEvent(""),
+			wantStatus: http.StatusOK,
+			wantEvents: 1,
+		},
+		{
+			name:       "valid event with delivery confirmation",
+			body:       testEvent(""),
+			headers:    map[string]string{deliveryConfirmationHeader: "true"},
+			wantStatus: http.StatusOK,
+			wantEvents: 1,
+		},
+		{
+			name:        "malformed json",
+			body:        `{"event_type":`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "INVALID_JSON",
+			deadLetters: 1,
+		},
+		{
+			name:        "missing required field",
+			body:        `{"event_type":"page_view","user_id":"user-1","session_id":"session-1"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "VALIDATION_ERROR",
+			deadLetters: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestSink()
+			router := newTestRouter(t, sink)
+
+			recorder := post(router, "/track", tt.body, tt.headers)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantCode != "" && !strings.Contains(recorder.Body.String(), `"`+tt.wantCode+`"`) {
+				t.Errorf("body = %s, want error code %s", recorder.Body, tt.wantCode)
+			}
+			if got := len(sink.Events()); got != tt.wantEvents {
+				t.Errorf("published %d events, want %d", got, tt.wantEvents)
+			}
+			if got := len(sink.DeadLetters()); got != tt.deadLetters {
+				t.Errorf("dead-lettered %d payloads, want %d", got, tt.deadLetters)
+			}
+		})
+	}
+}
+
+func TestTrackEventDedup(t *testing.T) {
+	confirm := map[string]string{deliveryConfirmationHeader: "true"}
+
+	tests := []struct {
+		name string
+		// first and second are sent in order; failFirst makes the sink reject the first
+		first, second   string
+		headers         map[string]string
+		failFirst       bool
+		wantStatus      int
+		wantDuplicate   bool
+		wantEvents      int
+		wantSameEventID bool
+	}{
+		{
+			name:            "repeated event_id is acknowledged once",
+			first:           testEvent("evt-1"),
+			second:          testEvent("evt-1"),
+			wantStatus:      http.StatusOK,
+			wantDuplicate:   true,
+			wantEvents:      1,
+			wantSameEventID: true,
+		},
+		{
+			name:            "repeated Idempotency-Key is acknowledged once",
+			first:           testEvent(""),
+			second:          testEvent(""),
+			headers:         map[string]string{idempotencyKeyHeader: "key-1"},
+			wantStatus:      http.StatusOK,
+			wantDuplicate:   true,
+			wantEvents:      1,
+			wantSameEventID: true,
+		},
+		{
+			name:       "different event_ids are both published",
+			first:      testEvent("evt-1"),
+			second:     testEvent("evt-2"),
+			wantStatus: http.StatusOK,
+			wantEvents: 2,
+		},
+		{
+			name:       "events without a key are both published",
+			first:      testEvent(""),
+			second:     testEvent(""),
+			wantStatus: http.StatusOK,
+			wantEvents: 2,
+		},
+		{
+			name:       "failed publish releases the key for a retry",
+			first:      testEvent("evt-1"),
+			second:     testEvent("evt-1"),
+			headers:    confirm,
+			failFirst:  true,
+			wantStatus: http.StatusOK,
+			wantEvents: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestSink()
+			router := newTestRouter(t, sink)
+
+			sink.fail.Store(tt.failFirst)
+			first := post(router, "/track", tt.first, tt.headers)
+			if tt.failFirst {
+				if first.Code != http.StatusBadGateway {
+					t.Fatalf("first status = %d, want %d: %s", first.Code, http.StatusBadGateway, first.Body)
+				}
+				sink.fail.Store(false)
+			}
+
+			second := post(router, "/track", tt.second, tt.headers)
+			if second.Code != tt.wantStatus {
+				t.Fatalf("second status = %d, want %d: %s", second.Code, tt.wantStatus, second.Body)
+			}
+
+			var firstResponse, secondResponse models.EventResponse
+			json.Unmarshal(first.Body.Bytes(), &firstResponse)
+			json.Unmarshal(second.Body.Bytes(), &secondResponse)
+
+			if secondResponse.Duplicate != tt.wantDuplicate {
+				t.Errorf("duplicate = %v, want %v", secondResponse.Duplicate, tt.wantDuplicate)
+			}
+			if tt.wantSameEventID && secondResponse.EventID != firstResponse.EventID {
+				t.Errorf("event_id = %q, want the first event's %q", secondResponse.EventID, firstResponse.EventID)
+			}
+			if got := len(sink.Events()); got != tt.wantEvents {
+				t.Errorf("published %d events, want %d", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestTrackEventDedupInFlight(t *testing.T) {
+	sink := newTestSink()
+	sink.entered = make(chan struct{})
+	sink.release = make(chan struct{})
+	router := newTestRouter(t, sink)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(router, "/track", testEvent("evt-1"), nil) }()
+	<-sink.entered
+
+	// The first event has not been published yet, so a repeat cannot be acknowledged
+	repeat := post(router, "/track", testEvent("evt-1"), nil)
+	if repeat.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", repeat.Code, http.StatusConflict, repeat.Body)
+	}
+	if !strings.Contains(repeat.Body.String(), `"DUPLICATE_IN_FLIGHT"`) {
+		t.Errorf("body = %s, want error code DUPLICATE_IN_FLIGHT", repeat.Body)
+	}
+	if repeat.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+
+	close(sink.release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Fatalf("first status = %d, want %d: %s", first.Code, http.StatusOK, first.Body)
+	}
+
+	sink.release = nil
+	var response models.EventResponse
+	after := post(router, "/track", testEvent("evt-1"), nil)
+	json.Unmarshal(after.Body.Bytes(), &response)
+	if after.Code != http.StatusOK || !response.Duplicate {
+		t.Errorf("after publish: status = %d, duplicate = %v, want 200 and a duplicate", after.Code, response.Duplicate)
+	}
+	if got := len(sink.Events()); got != 1 {
+		t.Errorf("published %d events, want 1", got)
+	}
+}
+
+func TestTrackBatchDedup(t *testing.T) {
+	sink := newTestSink()
+	router := newTestRouter(t, sink)
+
+	body := "[" + testEvent("evt-1") + "," + testEvent("evt-1") + "," + testEvent("evt-2") + "]"
+	recorder := post(router, "/batch", body, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	var response models.BatchEventResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if response.Accepted != 3 || response.Duplicates != 1 {
+		t.Errorf("accepted = %d, duplicates = %d, want 3 and 1", response.Accepted, response.Duplicates)
+	}
+	if got := len(sink.Events()); got != 2 {
+		t.Errorf("published %d events, want 2", got)
+	}
+}
+
+func TestTrackEventTenant(t *testing.T) {
+	withIdentity := func(identity auth.Identity) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		}
+	}
+	spoofed := map[string]string{tenantHeader: "other-tenant"}
+
+	tests := []struct {
+		name       string
+		middleware []gin.HandlerFunc
+		wantTenant string
+	}{
+		{
+			name:       "key with a tenant ignores the header",
+			middleware: []gin.HandlerFunc{withIdentity(auth.Identity{ProjectID: "shop", TenantID: "acme"})},
+			wantTenant: "acme",
+		},
+		{
+			name:       "key without a tenant ignores the header",
+			middleware: []gin.HandlerFunc{withIdentity(auth.Identity{ProjectID: "shop"})},
+			wantTenant: "",
+		},
+		{
+			name:       "unauthenticated request uses the header",
+			wantTenant: "other-tenant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestSink()
+			router := newTestRouter(t, sink, tt.middleware...)
+
+			recorder := post(router, "/track", testEvent(""), spoofed)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+			}
+			events := sink.Events()
+			if len(events) != 1 {
+				t.Fatalf("published %d events, want 1", len(events))
+			}
+			if events[0].TenantID != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", events[0].TenantID, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestTrackBatchRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(0), 3, time.Hour)
+	defer limiter.Close()
+	sink := newTestSink()
+	router := newTestRouter(t, sink, middleware.IPRateLimitMiddleware(limiter, zap.NewNop()))
+
+	// A batch pays per event, so it cannot carry more events than the IP's quota
+	events := make([]string, 5)
+	for i := range events {
+		events[i] = testEvent("")
+	}
+	recorder := post(router, "/batch", "["+strings.Join(events, ",")+"]", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	var response models.BatchEventResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if response.Accepted != 3 || response.Rejected != 2 {
+		t.Errorf("accepted = %d, rejected = %d, want 3 and 2", response.Accepted, response.Rejected)
+	}
+	for _, result := range response.Results[3:] {
+		if result.Code != "RATE_LIMITED" {
+			t.Errorf("event %d: code = %q, want RATE_LIMITED", result.Index, result.Code)
+		}
+	}
+	if got := len(sink.Events()); got != 3 {
+		t.Errorf("published %d events, want 3", got)
+	}
+
+	// The quota is used up, so the next request is refused outright
+	if recorder := post(router, "/track", testEvent(""), nil); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("next request status = %d, want %d", recorder.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestTrackBatchDeliveryConfirmation(t *testing.T) {
+	confirm := map[string]string{deliveryConfirmationHeader: "true"}
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		fail         bool
+		wantCode     string
+		wantDelivery bool
+	}{
+		{name: "without confirmation", wantDelivery: false},
+		{name: "with confirmation", headers: confirm, wantDelivery: true},
+		{name: "unacknowledged", headers: confirm, fail: true, wantCode: "SINK_DELIVERY_FAILED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestSink()
+			sink.fail.Store(tt.fail)
+			router := newTestRouter(t, sink)
+
+			recorder := post(router, "/batch", "["+testEvent("")+"]", tt.headers)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+			}
+
+			var response models.BatchEventResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			result := response.Results[0]
+			if result.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", result.Code, tt.wantCode)
+			}
+			if got := result.Delivery != nil; got != tt.wantDelivery {
+				t.Errorf("delivery reported = %v, want %v", got, tt.wantDelivery)
+			}
+		})
+	}
+}
+
+func TestTrackBatchSinkFailure(t *testing.T) {
+	sink := newTestSink()
+	sink.fail.Store(true)
+	router := newTestRouter(t, sink)
+
+	recorder := post(router, "/batch", "["+testEvent("")+","+testEvent("")+","+testEvent("")+"]", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	var response models.BatchEventResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	for _, result := range response.Results {
+		if result.Code != "SINK_ERROR" {
+			t.Errorf("event %d: code = %q, want SINK_ERROR", result.Index, result.Code)
+		}
+	}
+
+	// The first event is retried; once it failed, the others get one attempt each
+	if got, want := sink.attempts.Load(), int64(maxPublishAttempts+2); got != want {
+		t.Errorf("publish attempts = %d, want %d", got, want)
+	}
+}
+
+func TestPublishEventCancelled(t *testing.T) {
+	sink := newTestSink()
+	sink.fail.Store(true)
+	handler := NewEventHandler(sink, EventHandlerConfig{}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A client that went away stops the backoff instead of holding the handler
+	err := handler.publishEvent(ctx, models.EnrichedEvent{}, "request-1", maxPublishAttempts)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("publishEvent() error = %v, want context.Canceled", err)
+	}
+	if got := sink.attempts.Load(); got != 1 {
+		t.Errorf("publish attempts = %d, want 1", got)
+	}
+}
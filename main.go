@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"ingestion-service/auth"
 	"ingestion-service/config"
+	"ingestion-service/dedup"
+	"ingestion-service/enrichment"
 	"ingestion-service/handlers"
+	"ingestion-service/metrics"
+	"ingestion-service/ratelimit"
 	"ingestion-service/router"
+	"ingestion-service/schema"
 	"ingestion-service/services"
 	"log"
 	"net/http"
@@ -22,32 +29,173 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to initialize logger:", err)
 	}
-	defer logger.Sync()
 
+	if err := run(logger); err != nil {
+		logger.Fatal("Ingestion service failed", zap.Error(err))
+	}
+	logger.Sync()
+}
+
+// run starts the service and blocks until it is told to stop or a server fails. Failures
+// are returned rather than exiting, so the deferred cleanup, closing the sink above all,
+// always runs.
+func run(logger *zap.Logger) error {
 	logger.Info("Starting ingestion service")
 
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		logger.Fatal("Failed to load configuration", zap.Error(err))
+		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	// Initialize Kafka service
-	kafkaService, err := initializeKafkaService(cfg, logger)
+	// Initialize the event sink
+	sink, err := initializeSink(cfg, logger)
 	if err != nil {
-		logger.Fatal("Failed to initialize Kafka service", zap.Error(err))
+		return fmt.Errorf("failed to initialize event sinks: %w", err)
 	}
 	defer func() {
-		if err := kafkaService.Close(); err != nil {
-			logger.Error("Failed to close Kafka service", zap.Error(err))
+		if err := sink.Close(); err != nil {
+			logger.Error("Failed to close event sink", zap.String("sink", sink.Name()), zap.Error(err))
 		}
 	}()
 
+	// Load event schemas
+	schemas, err := schema.NewRegistry(cfg.Schema.Dir, schema.UnknownEventPolicy(cfg.Schema.UnknownEventPolicy))
+	if err != nil {
+		return fmt.Errorf("failed to load event schemas: %w", err)
+	}
+	logger.Info("Event schemas loaded",
+		zap.String("dir", cfg.Schema.Dir),
+		zap.Int("event_types", len(schemas.EventTypes())),
+		zap.String("unknown_event_policy", cfg.Schema.UnknownEventPolicy),
+	)
+
+	// Initialize the rate limiter
+	var limiter *ratelimit.Limiter
+	if cfg.Security.EnableRateLimiting {
+		limiter = ratelimit.NewLimiter(
+			ratelimit.NewMemoryBackend(cfg.Security.RateLimitWindow),
+			cfg.Security.RateLimitRequests,
+			cfg.Security.RateLimitWindow,
+		)
+		defer limiter.Close()
+		logger.Info("Rate limiting enabled",
+			zap.Int("requests", cfg.Security.RateLimitRequests),
+			zap.Int("key_requests", cfg.Security.RateLimitKeyRequests),
+			zap.Duration("window", cfg.Security.RateLimitWindow),
+		)
+	}
+
+	// Initialize the idempotency cache
+	var dedupCache *dedup.Cache
+	if cfg.Dedup.Enabled {
+		dedupCache = dedup.NewCache(cfg.Dedup.Window, cfg.Dedup.MaxEntries)
+	}
+
+	// Initialize enrichment
+	var geoIP *enrichment.GeoIPResolver
+	if cfg.Enrichment.GeoIPCityDB != "" || cfg.Enrichment.GeoIPASNDB != "" {
+		geoIP, err = enrichment.NewGeoIPResolver(enrichment.GeoIPConfig{
+			CityDB:         cfg.Enrichment.GeoIPCityDB,
+			ASNDB:          cfg.Enrichment.GeoIPASNDB,
+			ReloadInterval: cfg.Enrichment.GeoIPReloadInterval,
+		}, logger)
+		if err != nil {
+			return fmt.Errorf("failed to load GeoIP databases: %w", err)
+		}
+		defer geoIP.Close()
+		logger.Info("GeoIP databases loaded",
+			zap.String("city_db", cfg.Enrichment.GeoIPCityDB),
+			zap.String("asn_db", cfg.Enrichment.GeoIPASNDB),
+		)
+	}
+
+	var privacy *enrichment.PrivacyFilter
+	if cfg.Privacy.Enabled() {
+		privacy, err = enrichment.NewPrivacyFilter(enrichment.PrivacyConfig{
+			TruncateIP: cfg.Privacy.TruncateIP,
+			IPv4Prefix: cfg.Privacy.IPv4Prefix,
+			IPv6Prefix: cfg.Privacy.IPv6Prefix,
+			DropKeys:   cfg.Privacy.DropKeys,
+			HashKeys:   cfg.Privacy.HashKeys,
+			HashSalt:   cfg.Privacy.HashSalt,
+			Scrub:      cfg.Privacy.Scrub,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid privacy rules: %w", err)
+		}
+		logger.Info("Privacy rules enabled", zap.Strings("rules", privacy.Rules()))
+	}
+
+	// Register enrichment steps; custom steps are registered here under their own
+	// names and listed in ENRICHMENT_PIPELINE
+	enrichers := enrichment.NewRegistry()
+	enrichers.Register(enrichment.StepUserAgent, func() (enrichment.Enricher, error) {
+		return enrichment.NewUserAgentParser(cfg.Enrichment.UserAgentCacheSize), nil
+	})
+	enrichers.Register(enrichment.StepGeoIP, func() (enrichment.Enricher, error) {
+		if geoIP == nil {
+			return nil, fmt.Errorf("no GeoIP database configured")
+		}
+		return geoIP, nil
+	})
+	enrichers.Register(enrichment.StepPrivacy, func() (enrichment.Enricher, error) {
+		if privacy == nil {
+			return nil, fmt.Errorf("no privacy rules configured")
+		}
+		return privacy, nil
+	})
+
+	steps := make([]enrichment.StepConfig, 0, len(cfg.Enrichment.Steps))
+	for _, step := range cfg.Enrichment.Steps {
+		steps = append(steps, enrichment.StepConfig{
+			Name:    step.Name,
+			Timeout: step.Timeout,
+			OnError: enrichment.ErrorPolicy(step.OnError),
+		})
+	}
+	pipeline, err := enrichers.Build(steps, logger)
+	if err != nil {
+		return fmt.Errorf("failed to build enrichment pipeline: %w", err)
+	}
+	stepNames := make([]string, 0, len(steps))
+	for _, step := range steps {
+		stepNames = append(stepNames, step.Name)
+	}
+	logger.Info("Enrichment pipeline configured", zap.Strings("steps", stepNames))
+
 	// Initialize handlers
-	eventHandler := handlers.NewEventHandler(kafkaService, logger)
+	eventHandler := handlers.NewEventHandler(sink, handlers.EventHandlerConfig{
+		ConfirmDelivery: cfg.Server.ConfirmDelivery,
+		Schemas:         schemas,
+		RateLimiter:     limiter,
+		Dedup:           dedupCache,
+		Enrichment:      pipeline,
+		GeoIP:           geoIP,
+		Privacy:         privacy,
+	}, logger)
+
+	// Load write keys
+	var keys *auth.KeyStore
+	if cfg.Auth.Enabled {
+		keys, err = auth.NewKeyStore(cfg.Auth.KeysFile, cfg.Auth.ReloadInterval, logger)
+		if err != nil {
+			return fmt.Errorf("failed to load write keys: %w", err)
+		}
+		defer keys.Close()
+		logger.Info("Write keys loaded",
+			zap.String("file", cfg.Auth.KeysFile),
+			zap.Int("keys", keys.Len()),
+		)
+	} else {
+		logger.Warn("Write key authentication is disabled; event endpoints accept unauthenticated requests")
+	}
 
 	// Setup router with dependencies
-	router := router.SetupRouter(eventHandler, logger)
+	router, err := router.SetupRouter(eventHandler, cfg, keys, limiter, logger)
+	if err != nil {
+		return err
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -55,21 +203,33 @@ func main() {
 		Handler: router,
 	}
 
-	// Start server in a goroutine
+	// Start server in a goroutine; serverErr receives failures of either server
+	serverErr := make(chan error, 2)
 	go func() {
 		logger.Info("Starting HTTP server", zap.String("address", cfg.GetServerAddress()))
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Failed to start server", zap.Error(err))
+			serverErr <- fmt.Errorf("failed to start server: %w", err)
 		}
 	}()
 
+	// Start the dedicated metrics server, if configured
+	var metricsServer *http.Server
+	if cfg.SeparateMetricsServer() {
+		metricsServer = startMetricsServer(cfg, logger, serverErr)
+	}
+
 	// Display startup information
 	displayStartupInfo(cfg, logger)
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+
+	var runErr error
+	select {
+	case <-quit:
+	case runErr = <-serverErr:
+	}
 
 	logger.Info("Shutting down server...")
 
@@ -82,7 +242,14 @@ func main() {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			logger.Error("Metrics server forced to shutdown", zap.Error(err))
+		}
+	}
+
 	logger.Info("Server exited")
+	return runErr
 }
 
 // initializeLogger sets up the application logger
@@ -99,34 +266,264 @@ func initializeLogger() (*zap.Logger, error) {
 	return config.Build()
 }
 
+// initializeSink creates the configured sinks. A single unfiltered sink is used
+// directly; otherwise events fan out to every sink selecting them.
+func initializeSink(cfg *config.Config, logger *zap.Logger) (services.EventSink, error) {
+	var members []services.FanoutMember
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := newSink(cfg, sinkCfg, logger)
+		if err != nil {
+			for _, member := range members {
+				member.Sink.Close()
+			}
+			return nil, fmt.Errorf("failed to initialize sink %s: %w", sinkCfg.Name, err)
+		}
+		members = append(members, services.FanoutMember{
+			Name:     sinkCfg.Name,
+			Sink:     sink,
+			Required: sinkCfg.Policy == "required",
+			Filter: services.SinkFilter{
+				EventTypes: sinkCfg.EventTypes,
+				Tenants:    sinkCfg.Tenants,
+			},
+		})
+	}
+
+	if len(members) == 1 && len(cfg.Sinks[0].EventTypes) == 0 && len(cfg.Sinks[0].Tenants) == 0 {
+		return members[0].Sink, nil
+	}
+
+	fanout, err := services.NewFanoutSink(members, logger)
+	if err != nil {
+		for _, member := range members {
+			member.Sink.Close()
+		}
+		return nil, err
+	}
+	return fanout, nil
+}
+
+// newSink creates a single event sink
+func newSink(cfg *config.Config, sinkCfg config.SinkConfig, logger *zap.Logger) (services.EventSink, error) {
+	logger.Info("Initializing event sink",
+		zap.String("sink", sinkCfg.Name),
+		zap.String("type", sinkCfg.Type),
+		zap.String("policy", sinkCfg.Policy),
+		zap.Strings("event_types", sinkCfg.EventTypes),
+		zap.Strings("tenants", sinkCfg.Tenants),
+	)
+
+	switch sinkCfg.Type {
+	case services.SinkMemory:
+		logger.Warn("Using the in-memory event sink; events are not persisted",
+			zap.String("sink", sinkCfg.Name),
+			zap.Int("max_events", sinkCfg.MemoryMaxEvents),
+		)
+		return services.NewMemorySink(sinkCfg.MemoryMaxEvents, logger), nil
+	case services.SinkFile:
+		logger.Info("Initializing file sink",
+			zap.String("dir", sinkCfg.FileDir),
+			zap.Int64("max_bytes", sinkCfg.FileMaxBytes),
+			zap.Duration("max_age", sinkCfg.FileMaxAge),
+			zap.Bool("gzip", sinkCfg.FileGzip),
+		)
+		return services.NewFileSink(services.FileSinkConfig{
+			Dir:           sinkCfg.FileDir,
+			MaxBytes:      sinkCfg.FileMaxBytes,
+			MaxAge:        sinkCfg.FileMaxAge,
+			Gzip:          sinkCfg.FileGzip,
+			FlushInterval: sinkCfg.FileFlushInterval,
+			MaxOpenFiles:  sinkCfg.FileMaxOpenFiles,
+		}, logger)
+	case services.SinkNATS:
+		return initializeNATSSink(cfg, logger)
+	case services.SinkRedis:
+		return initializeRedisSink(cfg, logger)
+	case services.SinkWebhook:
+		endpoints := make([]services.WebhookEndpoint, len(cfg.Webhook.Endpoints))
+		for i, endpoint := range cfg.Webhook.Endpoints {
+			endpoints[i] = services.WebhookEndpoint{
+				Name:       endpoint.Name,
+				URL:        endpoint.URL,
+				Secret:     endpoint.Secret,
+				EventTypes: endpoint.EventTypes,
+			}
+		}
+		return services.NewWebhookSink(services.WebhookSinkConfig{
+			Endpoints:        endpoints,
+			Timeout:          cfg.Webhook.Timeout,
+			MaxRetries:       cfg.Webhook.MaxRetries,
+			BackoffInitial:   cfg.Webhook.BackoffInitial,
+			BackoffMax:       cfg.Webhook.BackoffMax,
+			Concurrency:      cfg.Webhook.Concurrency,
+			BatchSize:        cfg.Webhook.BatchSize,
+			BatchInterval:    cfg.Webhook.BatchInterval,
+			QueueSize:        cfg.Webhook.QueueSize,
+			BreakerThreshold: cfg.Webhook.BreakerThreshold,
+			BreakerCooldown:  cfg.Webhook.BreakerCooldown,
+		}, logger)
+	default:
+		return initializeKafkaService(cfg, sinkCfg.Kafka, logger)
+	}
+}
+
 // initializeKafkaService creates and initializes the Kafka service
-func initializeKafkaService(cfg *config.Config, logger *zap.Logger) (*services.KafkaService, error) {
+func initializeKafkaService(cfg *config.Config, kafka config.KafkaConfig, logger *zap.Logger) (*services.KafkaService, error) {
+	topicRoutes := make([]services.TopicRoute, len(kafka.TopicRoutes))
+	for i, route := range kafka.TopicRoutes {
+		topicRoutes[i] = services.TopicRoute{Pattern: route.Pattern, Topic: route.Topic}
+	}
+
 	kafkaConfig := services.KafkaConfig{
-		Brokers:         cfg.Kafka.Brokers,
-		Topic:           cfg.Kafka.Topic,
-		Acks:            cfg.Kafka.Acks,
-		Retries:         cfg.Kafka.Retries,
-		BatchSize:       cfg.Kafka.BatchSize,
-		LingerMs:        cfg.Kafka.LingerMs,
-		Compression:     cfg.Kafka.Compression,
-		MaxMessageBytes: cfg.Kafka.MaxMessageBytes,
+		Brokers:         kafka.Brokers,
+		Topic:           kafka.Topic,
+		TopicRoutes:     topicRoutes,
+		PartitionKey:    kafka.PartitionKey,
+		Acks:            kafka.Acks,
+		Retries:         kafka.Retries,
+		BatchSize:       kafka.BatchSize,
+		LingerMs:        kafka.LingerMs,
+		Compression:     kafka.Compression,
+		MaxMessageBytes: kafka.MaxMessageBytes,
+		DeliveryTimeout: kafka.DeliveryTimeout,
+
+		SpoolDir:            kafka.SpoolDir,
+		SpoolMaxBytes:       kafka.SpoolMaxBytes,
+		SpoolReplayInterval: kafka.SpoolReplayInterval,
+
+		DeadLetterTopic: kafka.DeadLetterTopic,
+
+		ReadinessErrorWindow:  cfg.Monitor.ReadinessErrorWindow,
+		ReadinessMaxErrorRate: cfg.Monitor.ReadinessMaxErrorRate,
+		ReadinessMinSamples:   cfg.Monitor.ReadinessMinSamples,
+		ReadinessTimeout:      cfg.Monitor.ReadinessTimeout,
 	}
 
 	logger.Info("Initializing Kafka service",
 		zap.Strings("brokers", kafkaConfig.Brokers),
 		zap.String("topic", kafkaConfig.Topic),
+		zap.Int("topic_routes", len(kafkaConfig.TopicRoutes)),
+		zap.String("partition_key", kafkaConfig.PartitionKey),
 		zap.String("compression", kafkaConfig.Compression),
+		zap.String("spool_dir", kafkaConfig.SpoolDir),
+		zap.String("dead_letter_topic", kafkaConfig.DeadLetterTopic),
 	)
 
 	return services.NewKafkaService(kafkaConfig, logger)
 }
 
+// initializeNATSSink creates the NATS JetStream sink
+func initializeNATSSink(cfg *config.Config, logger *zap.Logger) (*services.NATSSink, error) {
+	subjectRoutes := make([]services.TopicRoute, len(cfg.NATS.SubjectRoutes))
+	for i, route := range cfg.NATS.SubjectRoutes {
+		subjectRoutes[i] = services.TopicRoute{Pattern: route.Pattern, Topic: route.Topic}
+	}
+
+	natsConfig := services.NATSConfig{
+		URL:           cfg.NATS.URL,
+		Subject:       cfg.NATS.Subject,
+		SubjectRoutes: subjectRoutes,
+
+		Stream:       cfg.NATS.Stream,
+		CreateStream: cfg.NATS.CreateStream,
+		Replicas:     cfg.NATS.Replicas,
+		Compression:  cfg.NATS.Compression,
+
+		MaxPending:      cfg.NATS.MaxPending,
+		DeliveryTimeout: cfg.NATS.DeliveryTimeout,
+
+		DeadLetterSubject: cfg.NATS.DeadLetterSubject,
+
+		ReadinessErrorWindow:  cfg.Monitor.ReadinessErrorWindow,
+		ReadinessMaxErrorRate: cfg.Monitor.ReadinessMaxErrorRate,
+		ReadinessMinSamples:   cfg.Monitor.ReadinessMinSamples,
+		ReadinessTimeout:      cfg.Monitor.ReadinessTimeout,
+	}
+
+	logger.Info("Initializing NATS JetStream sink",
+		zap.String("stream", natsConfig.Stream),
+		zap.String("subject", natsConfig.Subject),
+		zap.Int("subject_routes", len(natsConfig.SubjectRoutes)),
+		zap.String("compression", natsConfig.Compression),
+		zap.String("dead_letter_subject", natsConfig.DeadLetterSubject),
+	)
+
+	return services.NewNATSSink(natsConfig, logger)
+}
+
+// initializeRedisSink creates the Redis Streams sink
+func initializeRedisSink(cfg *config.Config, logger *zap.Logger) (*services.RedisSink, error) {
+	streamRoutes := make([]services.TopicRoute, len(cfg.Redis.StreamRoutes))
+	for i, route := range cfg.Redis.StreamRoutes {
+		streamRoutes[i] = services.TopicRoute{Pattern: route.Pattern, Topic: route.Topic}
+	}
+
+	redisConfig := services.RedisConfig{
+		URL:          cfg.Redis.URL,
+		Stream:       cfg.Redis.Stream,
+		StreamRoutes: streamRoutes,
+
+		MaxLen:      cfg.Redis.MaxLen,
+		MinReplicas: cfg.Redis.MinReplicas,
+		Retries:     cfg.Redis.Retries,
+
+		BatchSize:       cfg.Redis.BatchSize,
+		LingerMs:        cfg.Redis.LingerMs,
+		QueueSize:       cfg.Redis.QueueSize,
+		MaxMessageBytes: cfg.Redis.MaxMessageBytes,
+		DeliveryTimeout: cfg.Redis.DeliveryTimeout,
+
+		DeadLetterStream: cfg.Redis.DeadLetterStream,
+
+		ReadinessErrorWindow:  cfg.Monitor.ReadinessErrorWindow,
+		ReadinessMaxErrorRate: cfg.Monitor.ReadinessMaxErrorRate,
+		ReadinessMinSamples:   cfg.Monitor.ReadinessMinSamples,
+		ReadinessTimeout:      cfg.Monitor.ReadinessTimeout,
+	}
+
+	logger.Info("Initializing Redis Streams sink",
+		zap.String("stream", redisConfig.Stream),
+		zap.Int("stream_routes", len(redisConfig.StreamRoutes)),
+		zap.Int64("max_len", redisConfig.MaxLen),
+		zap.Int("min_replicas", redisConfig.MinReplicas),
+		zap.String("dead_letter_stream", redisConfig.DeadLetterStream),
+	)
+
+	return services.NewRedisSink(redisConfig, logger)
+}
+
+// startMetricsServer serves Prometheus metrics on their own port
+func startMetricsServer(cfg *config.Config, logger *zap.Logger, serverErr chan<- error) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Monitor.MetricsEndpoint, metrics.Handler())
+
+	metricsServer := &http.Server{
+		Addr:    cfg.GetMetricsAddress(),
+		Handler: mux,
+	}
+
+	go func() {
+		logger.Info("Starting metrics server", zap.String("address", cfg.GetMetricsAddress()))
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- fmt.Errorf("failed to start metrics server: %w", err)
+		}
+	}()
+
+	return metricsServer
+}
+
 // displayStartupInfo prints startup information
 func displayStartupInfo(cfg *config.Config, logger *zap.Logger) {
 	logger.Info("Ingestion service started successfully",
 		zap.String("address", cfg.GetServerAddress()),
 		zap.String("health_endpoint", "/health"),
+		zap.String("liveness_endpoint", "/livez"),
+		zap.String("readiness_endpoint", "/readyz"),
 		zap.String("events_endpoint", "/api/v1/events/track"),
+		zap.String("batch_endpoint", "/api/v1/events/batch"),
+		zap.String("stream_endpoint", "/api/v1/events/stream"),
 		zap.String("stats_endpoint", "/api/v1/stats"),
+		zap.Bool("metrics_enabled", cfg.Monitor.EnableMetrics),
+		zap.String("metrics_endpoint", cfg.Monitor.MetricsEndpoint),
 	)
 }
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "ingestion"
+
+	// maxEventTypeLabels bounds the event_type label cardinality; client-supplied
+	// event types beyond this are reported as "other"
+	maxEventTypeLabels = 200
+)
+
+// Registry holds every collector exposed by the service
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestsTotal counts HTTP requests per route, method and status
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency per route, method and status
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// EventsAccepted counts events accepted for publishing per event type
+	EventsAccepted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_accepted_total",
+		Help:      "Total events accepted by event type.",
+	}, []string{"event_type"})
+
+	// EventsRejected counts rejected events per event type and error code
+	EventsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_rejected_total",
+		Help:      "Total events rejected by event type and error code.",
+	}, []string{"event_type", "code"})
+
+	// EventsDuplicate counts events acknowledged without republishing because their idempotency key repeated
+	EventsDuplicate = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_duplicate_total",
+		Help:      "Total duplicate events acknowledged without republishing by event type.",
+	}, []string{"event_type"})
+
+	// RateLimited counts requests and events refused by the rate limiter per dimension
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests and events refused by the rate limiter by dimension.",
+	}, []string{"dimension"})
+
+	// EnrichmentDuration observes how long each enrichment step takes
+	EnrichmentDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "enrichment_step_duration_seconds",
+		Help:      "Time spent in each enrichment step.",
+		Buckets:   []float64{.00005, .0001, .0005, .001, .005, .01, .05, .1, .5},
+	}, []string{"step"})
+
+	// EnrichmentErrors counts failed enrichment steps by reason (error or timeout)
+	EnrichmentErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enrichment_step_errors_total",
+		Help:      "Enrichment step failures by step and reason.",
+	}, []string{"step", "reason"})
+
+	// SinkPublishes counts events published to each sink by result (success or failure)
+	SinkPublishes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_publishes_total",
+		Help:      "Events published to each sink by result.",
+	}, []string{"sink", "result"})
+
+	// WebhookDeliveries counts events by webhook endpoint and outcome
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook event outcomes by endpoint and result (success, retry, failure, circuit_open, queue_full).",
+	}, []string{"endpoint", "result"})
+
+	// WebhookCircuitState reports each webhook endpoint's circuit breaker: 0 closed, 1 half-open, 2 open
+	WebhookCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "webhook_circuit_state",
+		Help:      "Webhook circuit breaker state by endpoint: 0 closed, 1 half-open, 2 open.",
+	}, []string{"endpoint"})
+
+	// KafkaEnqueueDuration observes how long handing a message to the producer takes
+	KafkaEnqueueDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_enqueue_duration_seconds",
+		Help:      "Time spent handing messages to the Kafka producer.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	})
+
+	// KafkaDeliveries counts broker delivery outcomes per topic
+	KafkaDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_deliveries_total",
+		Help:      "Kafka delivery outcomes by topic and result.",
+	}, []string{"topic", "result"})
+
+	// BrokerDeliveries counts NATS JetStream and Redis Streams delivery outcomes per destination
+	BrokerDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broker_deliveries_total",
+		Help:      "NATS and Redis delivery outcomes by broker, subject or stream, and result.",
+	}, []string{"broker", "destination", "result"})
+
+	// SpoolRecords counts records leaving the disk spool by result
+	SpoolRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spool_records_total",
+		Help:      "Records leaving the disk spool by result (replayed, dead_lettered, dropped, corrupt).",
+	}, []string{"result"})
+
+	// KafkaInFlight tracks messages enqueued but not yet acknowledged or failed
+	KafkaInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_producer_in_flight_messages",
+		Help:      "Messages handed to the Kafka producer awaiting a broker outcome.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		EventsAccepted,
+		EventsRejected,
+		EventsDuplicate,
+		RateLimited,
+		EnrichmentDuration,
+		EnrichmentErrors,
+		SinkPublishes,
+		WebhookDeliveries,
+		WebhookCircuitState,
+		KafkaEnqueueDuration,
+		KafkaDeliveries,
+		KafkaInFlight,
+		BrokerDeliveries,
+		SpoolRecords,
+	)
+}
+
+// Handler returns the HTTP handler serving the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RecordEventAccepted counts an accepted event
+func RecordEventAccepted(eventType string) {
+	EventsAccepted.WithLabelValues(eventTypeLabel(eventType)).Inc()
+}
+
+// RecordEventRejected counts a rejected event with its error code
+func RecordEventRejected(eventType, code string) {
+	EventsRejected.WithLabelValues(eventTypeLabel(eventType), code).Inc()
+}
+
+// RecordEventDuplicate counts a duplicate event
+func RecordEventDuplicate(eventType string) {
+	EventsDuplicate.WithLabelValues(eventTypeLabel(eventType)).Inc()
+}
+
+var (
+	eventTypesMu sync.Mutex
+	eventTypes   = make(map[string]struct{})
+)
+
+// eventTypeLabel maps a client-supplied event type to a bounded label value
+func eventTypeLabel(eventType string) string {
+	if eventType == "" {
+		return "unknown"
+	}
+
+	eventTypesMu.Lock()
+	defer eventTypesMu.Unlock()
+
+	if _, ok := eventTypes[eventType]; ok {
+		return eventType
+	}
+	if len(eventTypes) >= maxEventTypeLabels {
+		return "other"
+	}
+	eventTypes[eventType] = struct{}{}
+	return eventType
+}
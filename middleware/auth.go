@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"ingestion-service/auth"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// WriteKeyHeader carries the write key for clients that do not use basic auth
+const WriteKeyHeader = "X-Write-Key"
+
+// AuthMiddleware requires a valid write key and attaches its identity to the request context.
+// The key is read from the X-Write-Key header, from basic auth with the key as the
+// username and an empty password (as analytics SDKs send it), or from a bearer token.
+func AuthMiddleware(keys *auth.KeyStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := writeKey(c)
+		if key == "" {
+			c.Header("WWW-Authenticate", `Basic realm="ingestion"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"code":    "MISSING_WRITE_KEY",
+					"message": "A write key is required",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		identity, ok := keys.Lookup(key)
+		if !ok {
+			logger.Warn("Rejected request with unknown write key",
+				zap.String("path", c.Request.URL.Path),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.Header("WWW-Authenticate", `Basic realm="ingestion"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_WRITE_KEY",
+					"message": "Write key is invalid or disabled",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		// Browser requests are limited to the origins the key was issued for
+		if origin := c.GetHeader("Origin"); !applyKeyOrigins(c, origin, identity.AllowedOrigins) {
+			logger.Warn("Rejected write key used from a disallowed origin",
+				zap.String("key_id", identity.KeyID),
+				zap.String("origin", origin),
+			)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"code":    "ORIGIN_NOT_ALLOWED",
+					"message": "Origin is not allowed for this write key",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// writeKey extracts the write key from the request
+func writeKey(c *gin.Context) string {
+	if key := c.GetHeader(WriteKeyHeader); key != "" {
+		return key
+	}
+
+	if username, _, ok := c.Request.BasicAuth(); ok {
+		return username
+	}
+
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
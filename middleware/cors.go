@@ -1,30 +1,125 @@
 package middleware
 
 import (
-	"fmt"
+	"ingestion-service/config"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware handles Cross-Origin Resource Sharing
-func CORSMiddleware() gin.HandlerFunc {
+// exposedHeaders lists response headers browser clients may read
+const exposedHeaders = "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset"
+
+// CORSMiddleware handles Cross-Origin Resource Sharing. Requests from an allowed
+// origin get that origin echoed back; other origins get no CORS headers, and their
+// preflight requests are refused. AuthMiddleware later narrows the allowed origin
+// to the write key's allowlist.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
 	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
 
-		// Set CORS headers
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		// The response depends on the Origin header, so caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !originAllowed(cfg.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
 
 		// Handle preflight OPTIONS request
-		if c.Request.Method == "OPTIONS" {
-			fmt.Printf("✅ [CORS] Preflight request handled - allowing actual request\n")
+		if preflight {
+			allowOrigin(c, origin, cfg.AllowCredentials)
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			c.Header("Access-Control-Max-Age", maxAge)
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
-		// For actual requests, continue to the handler
-		fmt.Printf("➡️ [CORS] Actual request proceeding to handler\n")
+		allowOrigin(c, origin, cfg.AllowCredentials)
+		c.Header("Access-Control-Expose-Headers", exposedHeaders)
 		c.Next()
 	}
 }
+
+// allowOrigin echoes the origin as allowed to read the response
+func allowOrigin(c *gin.Context, origin string, allowCredentials bool) {
+	c.Header("Access-Control-Allow-Origin", origin)
+	if allowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// applyKeyOrigins narrows the allowed origin to a write key's allowlist: an origin the
+// key does not allow loses the grant CORSMiddleware gave it from the global list. The
+// key's list cannot extend the global one, because preflight requests carry no write key.
+// It reports whether the key allows the origin; keys without an allowlist allow every origin.
+func applyKeyOrigins(c *gin.Context, origin string, allowedOrigins []string) bool {
+	if origin == "" || len(allowedOrigins) == 0 || originAllowed(allowedOrigins, origin) {
+		return true
+	}
+
+	c.Writer.Header().Del("Access-Control-Allow-Origin")
+	c.Writer.Header().Del("Access-Control-Allow-Credentials")
+	c.Writer.Header().Del("Access-Control-Expose-Headers")
+	return false
+}
+
+// originAllowed reports whether the origin matches any of the patterns. Patterns are
+// an exact origin, * for any origin, or a wildcard subdomain such as https://*.example.com,
+// which matches subdomains at any depth but not example.com itself. A wildcard pattern
+// without a scheme matches any scheme.
+func originAllowed(patterns []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == "*":
+			return true
+		case pattern == origin:
+			return true
+		case strings.Contains(pattern, "*."):
+			if wildcardOriginMatch(pattern, origin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wildcardOriginMatch matches an origin against a [scheme://]*.domain[:port] pattern
+func wildcardOriginMatch(pattern, origin string) bool {
+	originScheme, originHost, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+
+	patternHost := pattern
+	if scheme, host, ok := strings.Cut(pattern, "://"); ok {
+		if scheme != originScheme {
+			return false
+		}
+		patternHost = host
+	}
+
+	suffix, ok := strings.CutPrefix(patternHost, "*")
+	if !ok {
+		return false
+	}
+	return strings.HasSuffix(originHost, suffix) && len(originHost) > len(suffix)
+}
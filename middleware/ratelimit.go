@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"ingestion-service/auth"
+	"ingestion-service/metrics"
+	"ingestion-service/ratelimit"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// rateLimitResultKey holds the most restrictive rate limit result reported so far, so that
+// a later limiter only replaces the headers when its bucket is tighter
+const rateLimitResultKey = "rate_limit_result"
+
+// IPRateLimitMiddleware limits requests per client IP. It runs before authentication, so
+// requests with missing or invalid write keys count against the client's budget too.
+// The request's token pays for its first event; batch and stream handlers charge the rest
+// through ratelimit.ChargeEvents. Backend failures are logged and the request is let through.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		result, err := limiter.Allow(ctx, "ip", c.ClientIP())
+		if err != nil {
+			logger.Warn("Rate limit check failed", zap.String("dimension", "ip"), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		if enforceRateLimit(c, "ip", result, logger) {
+			c.Request = c.Request.WithContext(ratelimit.WithQuota(ctx, limiter, "ip", c.ClientIP(), limiter.Limit()))
+			c.Next()
+		}
+	}
+}
+
+// KeyRateLimitMiddleware limits requests per authenticated write key and must run after
+// AuthMiddleware. Keys use their own quota from the key store, or keyLimit when none is set.
+// Like the IP limit, further events of a batch or stream are charged by the handler.
+// Backend failures are logged and the request is let through.
+func KeyRateLimitMiddleware(limiter *ratelimit.Limiter, keyLimit int, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		identity, ok := auth.IdentityFromContext(ctx)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		limit := keyLimit
+		if identity.RateLimit > 0 {
+			limit = identity.RateLimit
+		}
+		result, err := limiter.AllowN(ctx, "key", identity.KeyID, limit)
+		if err != nil {
+			logger.Warn("Rate limit check failed", zap.String("dimension", "key"), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		if enforceRateLimit(c, "key", result, logger) {
+			c.Request = c.Request.WithContext(ratelimit.WithQuota(ctx, limiter, "key", identity.KeyID, limit))
+			c.Next()
+		}
+	}
+}
+
+// enforceRateLimit reports the most restrictive bucket seen for the request in the response
+// headers, preferring one that refused it, and aborts refused requests. It reports whether
+// the request may proceed.
+func enforceRateLimit(c *gin.Context, dimension string, result ratelimit.Result, logger *zap.Logger) bool {
+	previous, seen := c.Get(rateLimitResultKey)
+	if !seen || !result.Allowed || result.Remaining < previous.(ratelimit.Result).Remaining {
+		ratelimit.WriteHeaders(c.Writer.Header(), result)
+		c.Set(rateLimitResultKey, result)
+	}
+
+	if result.Allowed {
+		return true
+	}
+
+	metrics.RateLimited.WithLabelValues(dimension).Inc()
+	logger.Warn("Request rate limited",
+		zap.String("dimension", dimension),
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("path", c.Request.URL.Path),
+	)
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": gin.H{
+			"code":    "RATE_LIMITED",
+			"message": "Too many requests, retry after the Retry-After interval",
+		},
+	})
+	c.Abort()
+	return false
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"ingestion-service/auth"
+	"ingestion-service/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	withIdentity := func(identity auth.Identity) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		}
+	}
+
+	tests := []struct {
+		name       string
+		middleware func(limiter *ratelimit.Limiter) []gin.HandlerFunc
+		// wantCodes are the statuses of consecutive requests
+		wantCodes []int
+		// unlimited requests carry no X-RateLimit-* headers
+		unlimited bool
+	}{
+		{
+			name: "ip limit",
+			middleware: func(limiter *ratelimit.Limiter) []gin.HandlerFunc {
+				return []gin.HandlerFunc{IPRateLimitMiddleware(limiter, zap.NewNop())}
+			},
+			wantCodes: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "default key limit",
+			middleware: func(limiter *ratelimit.Limiter) []gin.HandlerFunc {
+				return []gin.HandlerFunc{
+					withIdentity(auth.Identity{KeyID: "key-1"}),
+					KeyRateLimitMiddleware(limiter, 1, zap.NewNop()),
+				}
+			},
+			wantCodes: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "key quota from the key store",
+			middleware: func(limiter *ratelimit.Limiter) []gin.HandlerFunc {
+				return []gin.HandlerFunc{
+					withIdentity(auth.Identity{KeyID: "key-1", RateLimit: 3}),
+					KeyRateLimitMiddleware(limiter, 1, zap.NewNop()),
+				}
+			},
+			wantCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "unauthenticated requests skip the key limit",
+			middleware: func(limiter *ratelimit.Limiter) []gin.HandlerFunc {
+				return []gin.HandlerFunc{KeyRateLimitMiddleware(limiter, 1, zap.NewNop())}
+			},
+			wantCodes: []int{http.StatusOK, http.StatusOK},
+			unlimited: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(0), 2, time.Hour)
+			defer limiter.Close()
+
+			router := gin.New()
+			router.Use(tt.middleware(limiter)...)
+			router.POST("/track", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i, want := range tt.wantCodes {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/track", nil))
+				if recorder.Code != want {
+					t.Fatalf("request %d: status = %d, want %d", i, recorder.Code, want)
+				}
+				if (recorder.Header().Get("X-RateLimit-Limit") == "") != tt.unlimited {
+					t.Errorf("request %d: X-RateLimit-Limit = %q", i, recorder.Header().Get("X-RateLimit-Limit"))
+				}
+				if want == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After", i)
+				}
+			}
+		})
+	}
+}
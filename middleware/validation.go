@@ -7,17 +7,28 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// ValidationMiddleware creates a validation middleware for requests
-func ValidationMiddleware() gin.HandlerFunc {
+// maxRequestBodyBytes bounds request bodies on every route but the streaming ones
+const maxRequestBodyBytes = 10 * 1024 * 1024 // 10MB
+
+// ValidationMiddleware creates a validation middleware for requests. Bodies are limited to
+// 10MB except on streamRoutes, which limit each NDJSON line instead.
+func ValidationMiddleware(streamRoutes ...string) gin.HandlerFunc {
+	unlimited := make(map[string]bool, len(streamRoutes))
+	for _, route := range streamRoutes {
+		unlimited[route] = true
+	}
+
 	return func(c *gin.Context) {
+		contentType := c.GetHeader("Content-Type")
+		streaming := strings.Contains(contentType, "application/x-ndjson")
+
 		// Validate Content-Type for POST requests
 		if c.Request.Method == "POST" {
-			contentType := c.GetHeader("Content-Type")
-			if !strings.Contains(contentType, "application/json") {
+			if !strings.Contains(contentType, "application/json") && !streaming {
 				c.JSON(http.StatusBadRequest, gin.H{
 					"error": gin.H{
 						"code":    "INVALID_CONTENT_TYPE",
-						"message": "Content-Type must be application/json",
+						"message": "Content-Type must be application/json or application/x-ndjson",
 					},
 				})
 				c.Abort()
@@ -25,16 +36,19 @@ func ValidationMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Validate request size (optional)
-		if c.Request.ContentLength > 10*1024*1024 { // 10MB limit
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-				"error": gin.H{
-					"code":    "REQUEST_TOO_LARGE",
-					"message": "Request body too large",
-				},
-			})
-			c.Abort()
-			return
+		if !unlimited[c.FullPath()] {
+			// Reject declared sizes early; the reader also catches chunked bodies without one
+			if c.Request.ContentLength > maxRequestBodyBytes {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": gin.H{
+						"code":    "REQUEST_TOO_LARGE",
+						"message": "Request body too large",
+					},
+				})
+				c.Abort()
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBodyBytes)
 		}
 
 		c.Next()
@@ -2,19 +2,23 @@ package models
 
 import (
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
 // EventPayload represents the event structure from frontend
 type EventPayload struct {
-	EventType  string                 `json:"event_type"`
-	Timestamp  string                 `json:"timestamp"`
-	UserID     string                 `json:"user_id"`
-	SessionID  string                 `json:"session_id"`
-	PageURL    string                 `json:"page_url"`
-	EventData  map[string]interface{} `json:"event_data"`
-	ClientInfo ClientInfo             `json:"client_info"`
+	// EventID is an optional client-generated ID that makes retries idempotent
+	EventID       string                 `json:"event_id,omitempty"`
+	EventType     string                 `json:"event_type"`
+	SchemaVersion string                 `json:"schema_version,omitempty"`
+	Timestamp     string                 `json:"timestamp"`
+	UserID        string                 `json:"user_id"`
+	SessionID     string                 `json:"session_id"`
+	PageURL       string                 `json:"page_url"`
+	EventData     map[string]interface{} `json:"event_data"`
+	ClientInfo    ClientInfo             `json:"client_info"`
 }
 
 // ClientInfo represents client information
@@ -26,17 +30,62 @@ type ClientInfo struct {
 
 // EnrichedEvent represents the enriched event sent to Kafka
 type EnrichedEvent struct {
-	EventID        string                 `json:"event_id"`
-	RequestID      string                 `json:"request_id"`
-	EventType      string                 `json:"event_type"`
-	Timestamp      time.Time              `json:"timestamp"`
-	UserID         string                 `json:"user_id"`
-	SessionID      string                 `json:"session_id"`
-	PageURL        string                 `json:"page_url"`
-	EventData      map[string]interface{} `json:"event_data"`
-	ClientInfo     ClientInfo             `json:"client_info"`
-	ServiceInfo    ServiceInfo            `json:"service_info"`
-	ProcessingInfo ProcessingInfo         `json:"processing_info"`
+	EventID       string                 `json:"event_id"`
+	RequestID     string                 `json:"request_id"`
+	EventType     string                 `json:"event_type"`
+	SchemaVersion string                 `json:"schema_version,omitempty"`
+	ProjectID     string                 `json:"project_id,omitempty"`
+	TenantID      string                 `json:"tenant_id,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+	UserID        string                 `json:"user_id"`
+	SessionID     string                 `json:"session_id"`
+	PageURL       string                 `json:"page_url"`
+	EventData     map[string]interface{} `json:"event_data"`
+	ClientInfo    ClientInfo             `json:"client_info"`
+	UserAgentInfo *UserAgentInfo         `json:"user_agent_info,omitempty"`
+	ClientIP      string                 `json:"client_ip,omitempty"`
+	Geo           *GeoInfo               `json:"geo,omitempty"`
+	Privacy       *PrivacyInfo           `json:"privacy,omitempty"`
+	// EnrichmentErrors lists enrichment steps that failed under the tag error policy
+	EnrichmentErrors []string       `json:"enrichment_errors,omitempty"`
+	TraceContext     *TraceContext  `json:"trace_context,omitempty"`
+	ServiceInfo      ServiceInfo    `json:"service_info"`
+	ProcessingInfo   ProcessingInfo `json:"processing_info"`
+}
+
+// UserAgentInfo represents the parsed client user agent
+type UserAgentInfo struct {
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os,omitempty"`
+	OSVersion      string `json:"os_version,omitempty"`
+	// DeviceType is desktop, mobile, tablet, bot or unknown
+	DeviceType  string `json:"device_type"`
+	DeviceModel string `json:"device_model,omitempty"`
+	IsBot       bool   `json:"is_bot"`
+}
+
+// GeoInfo represents the location and network resolved from the client IP
+type GeoInfo struct {
+	CountryCode    string `json:"country_code,omitempty"`
+	Country        string `json:"country,omitempty"`
+	RegionCode     string `json:"region_code,omitempty"`
+	Region         string `json:"region,omitempty"`
+	City           string `json:"city,omitempty"`
+	TimeZone       string `json:"time_zone,omitempty"`
+	ASN            uint   `json:"asn,omitempty"`
+	ASOrganization string `json:"as_organization,omitempty"`
+}
+
+// PrivacyInfo records the privacy rules that modified an event before publishing
+type PrivacyInfo struct {
+	AppliedRules []string `json:"applied_rules"`
+}
+
+// TraceContext represents W3C trace context propagated from the incoming request
+type TraceContext struct {
+	TraceParent string `json:"traceparent"`
+	TraceState  string `json:"tracestate,omitempty"`
 }
 
 // ServiceInfo represents service metadata
@@ -55,28 +104,118 @@ type ProcessingInfo struct {
 
 // EventResponse represents the response sent back to the client
 type EventResponse struct {
-	Status    string    `json:"status"`
-	Message   string    `json:"message"`
-	EventID   string    `json:"event_id"`
-	RequestID string    `json:"request_id"`
-	Timestamp time.Time `json:"timestamp"`
+	Status    string        `json:"status"`
+	Message   string        `json:"message"`
+	EventID   string        `json:"event_id"`
+	RequestID string        `json:"request_id"`
+	Delivery  *DeliveryInfo `json:"delivery,omitempty"`
+	Duplicate bool          `json:"duplicate,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// DeliveryInfo represents the broker acknowledgement for a delivery-confirmed event
+type DeliveryInfo struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	// ID identifies the stored message on brokers without offsets, such as a Redis stream entry ID
+	ID string `json:"id,omitempty"`
+}
+
+// BatchEventResult represents the outcome of a single event within a batch
+type BatchEventResult struct {
+	Index     int          `json:"index"`
+	Status    string       `json:"status"`
+	EventID   string       `json:"event_id,omitempty"`
+	Duplicate bool         `json:"duplicate,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+	// Delivery is set for accepted events of delivery-confirmed batches
+	Delivery *DeliveryInfo `json:"delivery,omitempty"`
+}
+
+// BatchEventResponse represents the response sent back for a batch request
+type BatchEventResponse struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	Accepted  int    `json:"accepted"`
+	Rejected  int    `json:"rejected"`
+	// Duplicates counts accepted events that repeated an idempotency key and were not republished
+	Duplicates int                `json:"duplicates,omitempty"`
+	Results    []BatchEventResult `json:"results"`
+	Timestamp  time.Time          `json:"timestamp"`
+}
+
+// StreamLineError represents a rejected line within an NDJSON stream
+type StreamLineError struct {
+	Line    int          `json:"line"`
+	Code    string       `json:"code"`
+	Reason  string       `json:"reason"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// StreamEventResponse represents the response sent back for an NDJSON stream
+type StreamEventResponse struct {
+	Status          string            `json:"status"`
+	Message         string            `json:"message"`
+	RequestID       string            `json:"request_id"`
+	Received        int               `json:"received"`
+	Accepted        int               `json:"accepted"`
+	Rejected        int               `json:"rejected"`
+	Duplicates      int               `json:"duplicates,omitempty"`
+	Errors          []StreamLineError `json:"errors,omitempty"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+}
+
+// DeadLetterEnvelope wraps an event that could not be ingested, for later inspection or reprocessing
+type DeadLetterEnvelope struct {
+	ErrorCode    string    `json:"error_code"`
+	ErrorMessage string    `json:"error_message"`
+	RequestID    string    `json:"request_id"`
+	SourceTopic  string    `json:"source_topic,omitempty"`
+	RawPayload   string    `json:"raw_payload"`
+	Truncated    bool      `json:"truncated,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
 }
 
 // HealthResponse represents health check response
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
-	Service   string    `json:"service"`
-	Version   string    `json:"version"`
+	Status    string              `json:"status"`
+	Timestamp time.Time           `json:"timestamp"`
+	Service   string              `json:"service"`
+	Version   string              `json:"version"`
+	Checks    []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult describes the outcome of a single readiness check
+type HealthCheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
 }
 
 // ErrorResponse represents error responses
 type ErrorResponse struct {
-	Error struct {
-		Code      string `json:"code"`
-		Message   string `json:"message"`
-		RequestID string `json:"request_id"`
-	} `json:"error"`
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes the error carried by an ErrorResponse
+type ErrorDetail struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// FieldError describes a single invalid field within a payload
+type FieldError struct {
+	// Field is a JSON pointer to the offending value, e.g. /event_data/product_id
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // NewEventResponse creates a new event response
@@ -90,6 +229,68 @@ func NewEventResponse(requestID string) EventResponse {
 	}
 }
 
+// NewBatchEventResponse creates a batch response summarizing per-event results
+func NewBatchEventResponse(requestID string, results []BatchEventResult) BatchEventResponse {
+	accepted, duplicates := 0, 0
+	for _, result := range results {
+		if result.Status == "accepted" {
+			accepted++
+		}
+		if result.Duplicate {
+			duplicates++
+		}
+	}
+	rejected := len(results) - accepted
+
+	status := "success"
+	message := "All events received and processed successfully"
+	switch {
+	case accepted == 0:
+		status = "failed"
+		message = "No events in the batch were accepted"
+	case rejected > 0:
+		status = "partial"
+		message = "Some events in the batch were rejected"
+	}
+
+	return BatchEventResponse{
+		Status:     status,
+		Message:    message,
+		RequestID:  requestID,
+		Accepted:   accepted,
+		Rejected:   rejected,
+		Duplicates: duplicates,
+		Results:    results,
+		Timestamp:  time.Now().UTC(),
+	}
+}
+
+// NewStreamEventResponse creates a stream response from the processed line counts
+func NewStreamEventResponse(requestID string, accepted int, errors []StreamLineError, rejected int) StreamEventResponse {
+	status := "success"
+	message := "All events received and processed successfully"
+	switch {
+	case accepted == 0 && rejected > 0:
+		status = "failed"
+		message = "No events in the stream were accepted"
+	case rejected > 0:
+		status = "partial"
+		message = "Some events in the stream were rejected"
+	}
+
+	return StreamEventResponse{
+		Status:          status,
+		Message:         message,
+		RequestID:       requestID,
+		Received:        accepted + rejected,
+		Accepted:        accepted,
+		Rejected:        rejected,
+		Errors:          errors,
+		ErrorsTruncated: rejected > len(errors),
+		Timestamp:       time.Now().UTC(),
+	}
+}
+
 // NewHealthResponse creates a new health response
 func NewHealthResponse() HealthResponse {
 	return HealthResponse{
@@ -103,11 +304,7 @@ func NewHealthResponse() HealthResponse {
 // NewErrorResponse creates a new error response
 func NewErrorResponse(code, message, requestID string) ErrorResponse {
 	return ErrorResponse{
-		Error: struct {
-			Code      string `json:"code"`
-			Message   string `json:"message"`
-			RequestID string `json:"request_id"`
-		}{
+		Error: ErrorDetail{
 			Code:      code,
 			Message:   message,
 			RequestID: requestID,
@@ -115,6 +312,36 @@ func NewErrorResponse(code, message, requestID string) ErrorResponse {
 	}
 }
 
+// NewValidationErrorResponse creates an error response listing every invalid field
+func NewValidationErrorResponse(code, message, requestID string, details []FieldError) ErrorResponse {
+	response := NewErrorResponse(code, message, requestID)
+	response.Error.Details = details
+	return response
+}
+
+// NewDeadLetterEnvelope creates a dead-letter envelope, truncating the payload beyond maxPayloadBytes
+func NewDeadLetterEnvelope(rawPayload []byte, code, message, requestID string, maxPayloadBytes int) DeadLetterEnvelope {
+	envelope := DeadLetterEnvelope{
+		ErrorCode:    code,
+		ErrorMessage: message,
+		RequestID:    requestID,
+		RawPayload:   string(rawPayload),
+		Timestamp:    time.Now().UTC(),
+	}
+
+	if maxPayloadBytes > 0 && len(rawPayload) > maxPayloadBytes {
+		// Cut before a split rune, which JSON encoding would turn into U+FFFD
+		cut := maxPayloadBytes
+		for i := 1; i < utf8.UTFMax && cut > 0 && !utf8.RuneStart(rawPayload[cut]); i++ {
+			cut--
+		}
+		envelope.RawPayload = string(rawPayload[:cut])
+		envelope.Truncated = true
+	}
+
+	return envelope
+}
+
 // EnrichEvent creates an enriched event from the payload
 func EnrichEvent(payload EventPayload, requestID string) EnrichedEvent {
 	now := time.Now().UTC()
@@ -127,16 +354,23 @@ func EnrichEvent(payload EventPayload, requestID string) EnrichedEvent {
 		}
 	}
 
+	// Keep the client's event ID so downstream consumers can deduplicate too
+	eventID := payload.EventID
+	if eventID == "" {
+		eventID = uuid.New().String()
+	}
+
 	return EnrichedEvent{
-		EventID:    uuid.New().String(),
-		RequestID:  requestID,
-		EventType:  payload.EventType,
-		Timestamp:  timestamp,
-		UserID:     payload.UserID,
-		SessionID:  payload.SessionID,
-		PageURL:    payload.PageURL,
-		EventData:  payload.EventData,
-		ClientInfo: payload.ClientInfo,
+		EventID:       eventID,
+		RequestID:     requestID,
+		EventType:     payload.EventType,
+		SchemaVersion: payload.SchemaVersion,
+		Timestamp:     timestamp,
+		UserID:        payload.UserID,
+		SessionID:     payload.SessionID,
+		PageURL:       payload.PageURL,
+		EventData:     payload.EventData,
+		ClientInfo:    payload.ClientInfo,
 		ServiceInfo: ServiceInfo{
 			ServiceName:    "ingestion-service",
 			ServiceVersion: "1.0.0",
@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestNewDeadLetterEnvelopeTruncation(t *testing.T) {
+	tests := []struct {
+		name          string
+		payload       string
+		max           int
+		want          string
+		wantTruncated bool
+	}{
+		{name: "within the limit", payload: "héllo", max: 10, want: "héllo"},
+		{name: "ascii cut", payload: "hello", max: 3, want: "hel", wantTruncated: true},
+		{name: "cut inside a two-byte rune", payload: "hé", max: 2, want: "h", wantTruncated: true},
+		{name: "cut inside a four-byte rune", payload: "a😀b", max: 4, want: "a", wantTruncated: true},
+		{name: "cut after a rune", payload: "a😀b", max: 5, want: "a😀", wantTruncated: true},
+		{name: "no limit", payload: "hello", max: 0, want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := NewDeadLetterEnvelope([]byte(tt.payload), "CODE", "message", "req-1", tt.max)
+			if envelope.RawPayload != tt.want || envelope.Truncated != tt.wantTruncated {
+				t.Errorf("payload = %q, truncated = %v, want %q, %v", envelope.RawPayload, envelope.Truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps token buckets in process memory. Limits are enforced per
+// replica; idle buckets are evicted once they have refilled.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	done    chan struct{}
+	once    sync.Once
+}
+
+// bucket is a token bucket with lazily computed refills
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// NewMemoryBackend creates an in-memory backend that evicts idle buckets every cleanupInterval
+func NewMemoryBackend(cleanupInterval time.Duration) *MemoryBackend {
+	backend := &MemoryBackend{
+		buckets: make(map[string]*bucket),
+		done:    make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go backend.cleanup(cleanupInterval)
+	}
+
+	return backend
+}
+
+// Take removes cost tokens from the bucket for key
+func (b *MemoryBackend) Take(_ context.Context, key string, cost, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+	capacity := float64(limit)
+	rate := capacity / window.Seconds()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, ok := b.buckets[key]
+	if !ok {
+		current = &bucket{tokens: capacity, updated: now}
+		b.buckets[key] = current
+	}
+
+	// Refill for the time elapsed since the last request
+	current.tokens = math.Min(capacity, current.tokens+now.Sub(current.updated).Seconds()*rate)
+	current.updated = now
+
+	result := Result{Limit: limit}
+	if current.tokens >= float64(cost) {
+		current.tokens -= float64(cost)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((float64(cost) - current.tokens) / rate)
+	}
+
+	result.Remaining = int(current.tokens)
+	result.Reset = secondsToDuration((capacity - current.tokens) / rate)
+	current.full = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// Close stops the cleanup loop
+func (b *MemoryBackend) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return nil
+}
+
+// Len returns the number of tracked buckets
+func (b *MemoryBackend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buckets)
+}
+
+// cleanup drops buckets that have refilled, which are indistinguishable from new ones
+func (b *MemoryBackend) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-ticker.C:
+			b.mu.Lock()
+			for key, current := range b.buckets {
+				if !now.Before(current.full) {
+					delete(b.buckets, key)
+				}
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
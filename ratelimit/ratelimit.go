@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before the next request can be allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Backend stores token buckets. Implementations must be safe for concurrent use;
+// a shared backend such as Redis lets several replicas enforce one limit.
+type Backend interface {
+	// Take removes cost tokens from the bucket for key, which holds limit tokens
+	// and refills completely over window. Nothing is removed when the bucket holds fewer.
+	Take(ctx context.Context, key string, cost, limit int, window time.Duration) (Result, error)
+	Close() error
+}
+
+// Limiter applies one request budget per window to keys in different dimensions
+type Limiter struct {
+	backend Backend
+	limit   int
+	window  time.Duration
+}
+
+// NewLimiter creates a limiter allowing limit requests per window for each key
+func NewLimiter(backend Backend, limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		backend: backend,
+		limit:   limit,
+		window:  window,
+	}
+}
+
+// Allow takes a token for the id within a dimension such as "ip" or "user"
+func (l *Limiter) Allow(ctx context.Context, dimension, id string) (Result, error) {
+	return l.AllowN(ctx, dimension, id, l.limit)
+}
+
+// AllowN takes a token for the id using a limit that overrides the default, e.g. a per-key quota
+func (l *Limiter) AllowN(ctx context.Context, dimension, id string, limit int) (Result, error) {
+	return l.TakeN(ctx, dimension, id, limit, 1)
+}
+
+// TakeN takes cost tokens for the id at once, using limit as the bucket size
+func (l *Limiter) TakeN(ctx context.Context, dimension, id string, limit, cost int) (Result, error) {
+	return l.backend.Take(ctx, dimension+":"+id, cost, limit, l.window)
+}
+
+// Limit returns the default number of requests allowed per window
+func (l *Limiter) Limit() int {
+	return l.limit
+}
+
+// Window returns the period over which a bucket refills
+func (l *Limiter) Window() time.Duration {
+	return l.window
+}
+
+// Close releases the backend
+func (l *Limiter) Close() error {
+	return l.backend.Close()
+}
+
+// quotaKey is the context key holding the buckets that admitted a request
+type quotaKey struct{}
+
+// quota is a bucket that admitted a request and pays for its further events
+type quota struct {
+	limiter   *Limiter
+	dimension string
+	id        string
+	limit     int
+}
+
+// WithQuota returns a context recording that the bucket for id admitted the request,
+// so that ChargeEvents can debit the request's further events from it
+func WithQuota(ctx context.Context, limiter *Limiter, dimension, id string, limit int) context.Context {
+	quotas, _ := ctx.Value(quotaKey{}).([]quota)
+	quotas = append(quotas[:len(quotas):len(quotas)], quota{limiter: limiter, dimension: dimension, id: id, limit: limit})
+	return context.WithValue(ctx, quotaKey{}, quotas)
+}
+
+// ChargeEvents takes cost tokens from every bucket that admitted the request, so a batch or
+// stream pays per event rather than per request. It returns the dimension of the first bucket
+// refusing the charge, or "" when all allow it. Backend failures are returned alongside and
+// do not refuse the events.
+func ChargeEvents(ctx context.Context, cost int) (string, error) {
+	quotas, _ := ctx.Value(quotaKey{}).([]quota)
+
+	var failures []error
+	for _, q := range quotas {
+		result, err := q.limiter.TakeN(ctx, q.dimension, q.id, q.limit, cost)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s rate limit: %w", q.dimension, err))
+			continue
+		}
+		if !result.Allowed {
+			return q.dimension, errors.Join(failures...)
+		}
+	}
+	return "", errors.Join(failures...)
+}
+
+// WriteHeaders sets the X-RateLimit-* headers, and Retry-After when the request was refused
+func WriteHeaders(header http.Header, result Result) {
+	header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
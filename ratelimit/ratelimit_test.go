@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendTake(t *testing.T) {
+	backend := NewMemoryBackend(0)
+	defer backend.Close()
+	ctx := context.Background()
+
+	steps := []struct {
+		cost          int
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{cost: 1, wantAllowed: true, wantRemaining: 4},
+		{cost: 3, wantAllowed: true, wantRemaining: 1},
+		// A charge larger than the tokens left takes nothing
+		{cost: 2, wantAllowed: false, wantRemaining: 1},
+		{cost: 1, wantAllowed: true, wantRemaining: 0},
+		{cost: 1, wantAllowed: false, wantRemaining: 0},
+	}
+
+	for i, step := range steps {
+		result, err := backend.Take(ctx, "ip:10.0.0.1", step.cost, 5, time.Hour)
+		if err != nil {
+			t.Fatalf("step %d: Take: %v", i, err)
+		}
+		if result.Allowed != step.wantAllowed || result.Remaining != step.wantRemaining {
+			t.Errorf("step %d: allowed = %v, remaining = %d, want %v, %d",
+				i, result.Allowed, result.Remaining, step.wantAllowed, step.wantRemaining)
+		}
+		if !result.Allowed && result.RetryAfter <= 0 {
+			t.Errorf("step %d: refused without a Retry-After", i)
+		}
+	}
+
+	// Other keys have their own bucket
+	if result, _ := backend.Take(ctx, "ip:10.0.0.2", 5, 5, time.Hour); !result.Allowed {
+		t.Error("a separate key was refused")
+	}
+}
+
+func TestChargeEvents(t *testing.T) {
+	limiter := NewLimiter(NewMemoryBackend(0), 3, time.Hour)
+	defer limiter.Close()
+
+	// Without recorded quotas nothing is charged
+	if dimension, err := ChargeEvents(context.Background(), 10); dimension != "" || err != nil {
+		t.Fatalf("ChargeEvents without quotas = %q, %v, want no refusal", dimension, err)
+	}
+
+	ctx := WithQuota(context.Background(), limiter, "ip", "10.0.0.1", 3)
+	ctx = WithQuota(ctx, limiter, "key", "key-1", 2)
+
+	if dimension, err := ChargeEvents(ctx, 1); dimension != "" || err != nil {
+		t.Fatalf("first charge = %q, %v, want no refusal", dimension, err)
+	}
+	if dimension, err := ChargeEvents(ctx, 1); dimension != "" || err != nil {
+		t.Fatalf("second charge = %q, %v, want no refusal", dimension, err)
+	}
+	// The key's bucket of 2 is empty while the IP still has a token
+	if dimension, _ := ChargeEvents(ctx, 1); dimension != "key" {
+		t.Errorf("third charge refused by %q, want key", dimension)
+	}
+}
@@ -1,8 +1,13 @@
 package router
 
 import (
+	"fmt"
+	"ingestion-service/auth"
+	"ingestion-service/config"
 	"ingestion-service/handlers"
+	"ingestion-service/metrics"
 	"ingestion-service/middleware"
+	"ingestion-service/ratelimit"
 	"net/http"
 	"time"
 
@@ -10,25 +15,65 @@ import (
 	"go.uber.org/zap"
 )
 
-// SetupRouter configures and returns the Gin router with dependencies
-func SetupRouter(eventHandler *handlers.EventHandler, logger *zap.Logger) *gin.Engine {
+// streamRoute is the NDJSON streaming endpoint, which limits each line rather than the whole body
+const streamRoute = "/api/v1/events/stream"
+
+// SetupRouter configures and returns the Gin router with dependencies.
+// Event ingestion routes require a write key when keys is non-nil and are
+// rate limited per client IP and write key when limiter is non-nil.
+func SetupRouter(eventHandler *handlers.EventHandler, cfg *config.Config, keys *auth.KeyStore, limiter *ratelimit.Limiter, logger *zap.Logger) (*gin.Engine, error) {
 	// Create Gin router
 	router := gin.New()
 
+	// Only honour X-Forwarded-For from known proxies, so clients cannot spoof their IP
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
 	// Add middleware
-	router.Use(middleware.CORSMiddleware())
+	if cfg.Monitor.EnableMetrics {
+		router.Use(middleware.MetricsMiddleware())
+	}
+	router.Use(middleware.CORSMiddleware(cfg.CORS))
 	router.Use(middleware.LoggingMiddleware(logger))
-	router.Use(middleware.ValidationMiddleware())
+	router.Use(middleware.ValidationMiddleware(streamRoute))
 	router.Use(gin.Recovery())
 
 	// Health check endpoint
 	router.GET("/health", eventHandler.HealthCheck)
 
+	// Liveness and readiness probes
+	router.GET("/livez", eventHandler.Liveness)
+	router.GET("/readyz", eventHandler.Readiness)
+
+	// Metrics endpoint, unless it is served on its own port
+	if cfg.Monitor.EnableMetrics && !cfg.SeparateMetricsServer() {
+		router.GET(cfg.Monitor.MetricsEndpoint, gin.WrapH(metrics.Handler()))
+	}
+
 	// API routes
 	api := router.Group("/api/v1")
 	{
+		// The IP limit runs before authentication so invalid keys cannot bypass it
+		events := api.Group("/events")
+		if limiter != nil {
+			events.Use(middleware.IPRateLimitMiddleware(limiter, logger))
+		}
+		if keys != nil {
+			events.Use(middleware.AuthMiddleware(keys, logger))
+			if limiter != nil {
+				events.Use(middleware.KeyRateLimitMiddleware(limiter, cfg.Security.RateLimitKeyRequests, logger))
+			}
+		}
+
 		// Event tracking endpoint
-		api.POST("/events/track", eventHandler.TrackEvent)
+		events.POST("/track", eventHandler.TrackEvent)
+
+		// Batch event tracking endpoint
+		events.POST("/batch", eventHandler.TrackBatch)
+
+		// NDJSON streaming ingestion endpoint
+		events.POST("/stream", eventHandler.StreamEvents)
 
 		// Stats endpoint
 		api.GET("/stats", eventHandler.GetStats)
@@ -40,10 +85,14 @@ func SetupRouter(eventHandler *handlers.EventHandler, logger *zap.Logger) *gin.E
 	logger.Info("Router configured successfully",
 		zap.String("health_endpoint", "/health"),
 		zap.String("events_endpoint", "/api/v1/events/track"),
+		zap.String("batch_endpoint", "/api/v1/events/batch"),
+		zap.String("stream_endpoint", streamRoute),
 		zap.String("stats_endpoint", "/api/v1/stats"),
+		zap.Bool("auth_enabled", keys != nil),
+		zap.Bool("rate_limiting_enabled", limiter != nil),
 	)
 
-	return router
+	return router, nil
 }
 
 // statusCheck handles status check requests (legacy endpoint)
@@ -56,7 +105,11 @@ func statusCheck(c *gin.Context) {
 		"message":   "Service is ready to receive events",
 		"endpoints": gin.H{
 			"health": "/health",
+			"livez":  "/livez",
+			"readyz": "/readyz",
 			"events": "/api/v1/events/track",
+			"batch":  "/api/v1/events/batch",
+			"stream": "/api/v1/events/stream",
 			"stats":  "/api/v1/stats",
 		},
 	}
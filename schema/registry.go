@@ -0,0 +1,386 @@
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"ingestion-service/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// envelopeSchema describes the fields every event must carry
+//
+//go:embed envelope.json
+var envelopeSchema string
+
+// defaultVersion is the version assigned to schemas stored as <event_type>.json
+const defaultVersion = "1"
+
+// UnknownEventPolicy decides what happens to events without a registered schema
+type UnknownEventPolicy string
+
+const (
+	// PolicyAllow lets events without a registered schema through after envelope validation
+	PolicyAllow UnknownEventPolicy = "allow"
+	// PolicyReject rejects events without a registered schema
+	PolicyReject UnknownEventPolicy = "reject"
+)
+
+// Violation describes a single schema failure within a payload
+type Violation struct {
+	// Pointer is a JSON pointer to the offending value, e.g. /event_data/product_id
+	Pointer string
+	// Code is a machine-readable error code, e.g. REQUIRED
+	Code string
+	// Keyword is the JSON Schema keyword that failed, e.g. required
+	Keyword string
+	Message string
+}
+
+// violationCodes maps JSON Schema keywords to machine-readable error codes
+var violationCodes = map[string]string{
+	"required":               "REQUIRED",
+	"type":                   "INVALID_TYPE",
+	"format":                 "INVALID_FORMAT",
+	"enum":                   "INVALID_VALUE",
+	"const":                  "INVALID_VALUE",
+	"minimum":                "OUT_OF_RANGE",
+	"maximum":                "OUT_OF_RANGE",
+	"exclusiveMinimum":       "OUT_OF_RANGE",
+	"exclusiveMaximum":       "OUT_OF_RANGE",
+	"multipleOf":             "OUT_OF_RANGE",
+	"minLength":              "TOO_SHORT",
+	"maxLength":              "TOO_LONG",
+	"minItems":               "TOO_SHORT",
+	"maxItems":               "TOO_LONG",
+	"minProperties":          "TOO_SHORT",
+	"maxProperties":          "TOO_LONG",
+	"uniqueItems":            "DUPLICATE_ITEMS",
+	"pattern":                "PATTERN_MISMATCH",
+	"additionalProperties":   "UNEXPECTED_PROPERTY",
+	"unknown_event_type":     "UNKNOWN_EVENT_TYPE",
+	"unknown_schema_version": "UNKNOWN_SCHEMA_VERSION",
+}
+
+// violationCode returns the error code for a keyword
+func violationCode(keyword string) string {
+	if code, ok := violationCodes[keyword]; ok {
+		return code
+	}
+	return "SCHEMA_VIOLATION"
+}
+
+// ValidationError reports every violation found in a payload
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error returns the first violation message
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 0 {
+		return "payload does not match schema"
+	}
+	return e.Violations[0].Message
+}
+
+// Registry validates event payloads against the envelope schema and the
+// event_data schema registered for their event type and version.
+//
+// Schemas are loaded from a directory laid out as either
+// <dir>/<event_type>.json (version 1) or <dir>/<event_type>/<version>.json.
+type Registry struct {
+	envelope *jsonschema.Schema
+	schemas  map[string]map[string]*jsonschema.Schema
+	latest   map[string]string
+	policy   UnknownEventPolicy
+}
+
+// NewRegistry compiles the envelope schema and every event schema under dir.
+// An empty dir yields a registry that only enforces the envelope.
+func NewRegistry(dir string, policy UnknownEventPolicy) (*Registry, error) {
+	if policy != PolicyAllow && policy != PolicyReject {
+		return nil, fmt.Errorf("invalid unknown event policy: %s", policy)
+	}
+
+	compiler := newCompiler()
+	if err := compiler.AddResource("envelope.json", strings.NewReader(envelopeSchema)); err != nil {
+		return nil, fmt.Errorf("failed to load envelope schema: %w", err)
+	}
+	envelope, err := compiler.Compile("envelope.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile envelope schema: %w", err)
+	}
+
+	registry := &Registry{
+		envelope: envelope,
+		schemas:  make(map[string]map[string]*jsonschema.Schema),
+		latest:   make(map[string]string),
+		policy:   policy,
+	}
+
+	if dir != "" {
+		if err := registry.load(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// newCompiler creates a compiler that enforces formats such as date-time
+func newCompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	return compiler
+}
+
+// load compiles every schema file in the directory
+func (r *Registry) load(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read schema directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if !entry.IsDir() {
+			if eventType, ok := strings.CutSuffix(entry.Name(), ".json"); ok {
+				if err := r.add(eventType, defaultVersion, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		versions, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read schema directory: %w", err)
+		}
+		for _, version := range versions {
+			if name, ok := strings.CutSuffix(version.Name(), ".json"); ok && !version.IsDir() {
+				if err := r.add(entry.Name(), name, filepath.Join(path, version.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// add compiles a schema file and registers it for the event type and version
+func (r *Registry) add(eventType, version, path string) error {
+	if _, exists := r.schemas[eventType][version]; exists {
+		return fmt.Errorf("duplicate schema for %s version %s: %s", eventType, version, path)
+	}
+
+	compiled, err := newCompiler().Compile(path)
+	if err != nil {
+		return fmt.Errorf("failed to compile schema %s: %w", path, err)
+	}
+
+	if r.schemas[eventType] == nil {
+		r.schemas[eventType] = make(map[string]*jsonschema.Schema)
+	}
+	r.schemas[eventType][version] = compiled
+
+	if latest, ok := r.latest[eventType]; !ok || compareVersions(version, latest) > 0 {
+		r.latest[eventType] = version
+	}
+	return nil
+}
+
+// Validate checks the payload and returns the schema version it was validated against.
+// Events that omit schema_version are validated against the latest registered version.
+func (r *Registry) Validate(payload models.EventPayload) (string, error) {
+	document, err := toDocument(payload)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.envelope.Validate(document); err != nil {
+		return "", toValidationError(err, "")
+	}
+
+	versions, known := r.schemas[payload.EventType]
+	if !known {
+		if r.policy == PolicyReject {
+			return "", &ValidationError{Violations: []Violation{{
+				Pointer: "/event_type",
+				Code:    violationCode("unknown_event_type"),
+				Keyword: "unknown_event_type",
+				Message: fmt.Sprintf("event_type %q has no registered schema", payload.EventType),
+			}}}
+		}
+		return payload.SchemaVersion, nil
+	}
+
+	version := payload.SchemaVersion
+	if version == "" {
+		version = r.latest[payload.EventType]
+	}
+
+	compiled, ok := versions[version]
+	if !ok {
+		return "", &ValidationError{Violations: []Violation{{
+			Pointer: "/schema_version",
+			Code:    violationCode("unknown_schema_version"),
+			Keyword: "unknown_schema_version",
+			Message: fmt.Sprintf("schema_version %q is not registered for event_type %q", version, payload.EventType),
+		}}}
+	}
+
+	if err := compiled.Validate(document.(map[string]interface{})["event_data"]); err != nil {
+		return "", toValidationError(err, "/event_data")
+	}
+
+	return version, nil
+}
+
+// EventTypes returns the registered event types and their latest versions
+func (r *Registry) EventTypes() map[string]string {
+	eventTypes := make(map[string]string, len(r.latest))
+	for eventType, version := range r.latest {
+		eventTypes[eventType] = version
+	}
+	return eventTypes
+}
+
+// Policy returns the policy applied to events without a registered schema
+func (r *Registry) Policy() UnknownEventPolicy {
+	return r.policy
+}
+
+// toDocument converts the payload to the generic form the validator expects
+func toDocument(payload models.EventPayload) (interface{}, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload for validation: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode payload for validation: %w", err)
+	}
+	return document, nil
+}
+
+// toValidationError flattens a validator error into violations, prefixing instance pointers
+func toValidationError(err error, prefix string) error {
+	schemaErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	var violations []Violation
+	collectViolations(schemaErr, prefix, &violations)
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Pointer < violations[j].Pointer
+	})
+	return &ValidationError{Violations: violations}
+}
+
+// collectViolations walks the error tree and records its leaves
+func collectViolations(err *jsonschema.ValidationError, prefix string, violations *[]Violation) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collectViolations(cause, prefix, violations)
+		}
+		return
+	}
+
+	pointer := prefix + err.InstanceLocation
+	keyword := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+
+	switch keyword {
+	case "required":
+		// One violation per missing property, pointing at the property itself
+		for _, name := range quotedNames(strings.TrimPrefix(err.Message, "missing properties: ")) {
+			propertyPointer := pointer + "/" + name
+			*violations = append(*violations, Violation{
+				Pointer: propertyPointer,
+				Code:    violationCode(keyword),
+				Keyword: keyword,
+				Message: fmt.Sprintf("%s is required", fieldName(propertyPointer)),
+			})
+		}
+	case "additionalProperties":
+		// One violation per unexpected property, pointing at the property itself
+		list := strings.TrimSuffix(strings.TrimPrefix(err.Message, "additionalProperties "), " not allowed")
+		for _, name := range quotedNames(list) {
+			propertyPointer := pointer + "/" + name
+			*violations = append(*violations, Violation{
+				Pointer: propertyPointer,
+				Code:    violationCode(keyword),
+				Keyword: keyword,
+				Message: fmt.Sprintf("%s is not allowed", fieldName(propertyPointer)),
+			})
+		}
+	case "minLength":
+		violation := Violation{
+			Pointer: pointer,
+			Code:    violationCode(keyword),
+			Keyword: keyword,
+			Message: fmt.Sprintf("%s: %s", fieldName(pointer), err.Message),
+		}
+		if strings.HasSuffix(err.Message, "but got 0") {
+			// An empty string for a field that must be set is reported as missing
+			violation.Code = violationCode("required")
+			violation.Message = fmt.Sprintf("%s is required", fieldName(pointer))
+		}
+		*violations = append(*violations, violation)
+	case "format":
+		message := fmt.Sprintf("%s: %s", fieldName(pointer), err.Message)
+		if strings.HasSuffix(err.Message, "'date-time'") {
+			message = fmt.Sprintf("invalid %s format, expected RFC3339", fieldName(pointer))
+		}
+		*violations = append(*violations, Violation{Pointer: pointer, Code: violationCode(keyword), Keyword: keyword, Message: message})
+	default:
+		*violations = append(*violations, Violation{
+			Pointer: pointer,
+			Code:    violationCode(keyword),
+			Keyword: keyword,
+			Message: fmt.Sprintf("%s: %s", fieldName(pointer), err.Message),
+		})
+	}
+}
+
+// quotedNames extracts the property names from a "'a', 'b'" list in a validator message
+func quotedNames(list string) []string {
+	var names []string
+	for _, quoted := range strings.Split(list, ", ") {
+		names = append(names, strings.Trim(quoted, "'"))
+	}
+	return names
+}
+
+// fieldName renders a JSON pointer as a dotted field name for messages
+func fieldName(pointer string) string {
+	if pointer == "" {
+		return "payload"
+	}
+	return strings.ReplaceAll(strings.TrimPrefix(pointer, "/"), "/", ".")
+}
+
+// compareVersions orders versions numerically when both are integers, lexically otherwise
+func compareVersions(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		return aNum - bNum
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitState is the state of a circuit breaker, exported as a metric value
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// circuitBreaker stops calls to a failing dependency. After threshold consecutive
+// failures it opens for cooldown, then lets a single trial call through: success
+// closes it again and failure reopens it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// newCircuitBreaker creates a closed breaker
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may proceed, moving an open breaker whose cooldown
+// has passed to half-open
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.trial = true
+		return true
+	case circuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record registers the outcome of an allowed call and returns the resulting state
+func (b *circuitBreaker) record(success bool) circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.state = circuitClosed
+		b.failures = 0
+		return b.state
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+	return b.state
+}
+
+// isOpen reports whether calls are currently refused. A breaker whose cooldown has
+// passed is not open, as the next call will be let through as a trial.
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == circuitOpen && time.Since(b.openedAt) < b.cooldown
+}
+
+// String returns the state name
+func (b *circuitBreaker) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
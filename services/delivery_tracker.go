@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"ingestion-service/models"
+	"sync"
+	"time"
+)
+
+// deliveryTracker counts broker delivery outcomes over a sliding window of one-second buckets
+type deliveryTracker struct {
+	mu      sync.Mutex
+	buckets []deliveryBucket
+}
+
+// deliveryBucket holds the outcomes recorded during one second
+type deliveryBucket struct {
+	second    int64
+	successes int64
+	failures  int64
+}
+
+// newDeliveryTracker creates a tracker covering the given window
+func newDeliveryTracker(window time.Duration) *deliveryTracker {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &deliveryTracker{buckets: make([]deliveryBucket, seconds)}
+}
+
+// record adds a delivery outcome to the current bucket
+func (t *deliveryTracker) record(success bool) {
+	now := time.Now().Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := &t.buckets[now%int64(len(t.buckets))]
+	if bucket.second != now {
+		*bucket = deliveryBucket{second: now}
+	}
+
+	if success {
+		bucket.successes++
+	} else {
+		bucket.failures++
+	}
+}
+
+// errorRate returns the failure ratio and the number of outcomes within the window
+func (t *deliveryTracker) errorRate() (float64, int64) {
+	now := time.Now().Unix()
+	window := int64(len(t.buckets))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var successes, failures int64
+	for _, bucket := range t.buckets {
+		if now-bucket.second < window {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+
+	total := successes + failures
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failures) / float64(total), total
+}
+
+// readinessCheck fails once at least minSamples outcomes were recorded and their error rate exceeds maxRate
+func (t *deliveryTracker) readinessCheck(maxRate float64, minSamples int) (models.HealthCheckResult, bool) {
+	rate, samples := t.errorRate()
+	check := models.HealthCheckResult{
+		Name:    "delivery_error_rate",
+		Status:  "pass",
+		Message: fmt.Sprintf("%.2f over %d deliveries", rate, samples),
+	}
+	if samples >= int64(minSamples) && rate > maxRate {
+		check.Status = "fail"
+		return check, false
+	}
+	return check, true
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// SinkFanout names the sink that publishes to several sinks
+	SinkFanout = "fanout"
+
+	// fanoutPublishAttempts is how often a required member is tried before PublishEvent fails
+	fanoutPublishAttempts = 3
+
+	// fanoutDrainTimeout bounds how long Close waits for best-effort publishes
+	fanoutDrainTimeout = 10 * time.Second
+)
+
+var (
+	// ErrRetriesExhausted is returned by sinks that already retried a publish themselves
+	ErrRetriesExhausted = errors.New("publish retries exhausted")
+
+	// ErrFanoutClosed is recorded for best-effort publishes attempted after Close
+	ErrFanoutClosed = errors.New("fanout sink is closed")
+)
+
+// FanoutMember is a sink that receives a copy of every event
+type FanoutMember struct {
+	// Name identifies the member in logs, metrics and stats; defaults to the sink's name
+	Name string
+	Sink EventSink
+	// Required members must accept an event for it to be acknowledged. Failures of
+	// other members are logged and counted but do not fail the publish.
+	Required bool
+	// Filter selects the events the member receives
+	Filter SinkFilter
+}
+
+// SinkFilter selects events by event type and tenant. An empty list matches every event.
+type SinkFilter struct {
+	// EventTypes are exact event types, globs or re: regular expressions, as in topic routes
+	EventTypes []string
+	// Tenants are exact tenant IDs; events without a tenant never match a tenant filter
+	Tenants []string
+}
+
+// FanoutSink publishes every event to several sinks concurrently
+type FanoutSink struct {
+	members []fanoutMember
+	logger  *zap.Logger
+
+	// background tracks best-effort publishes still running after their request returned
+	mu         sync.RWMutex
+	closed     bool
+	background sync.WaitGroup
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// fanoutMember is a member with its compiled filter
+type fanoutMember struct {
+	FanoutMember
+	eventTypes []func(eventType string) bool
+	tenants    map[string]bool
+}
+
+// NewFanoutSink creates a sink publishing to members; at least one must be required
+func NewFanoutSink(members []FanoutMember, logger *zap.Logger) (*FanoutSink, error) {
+	sink := &FanoutSink{logger: logger}
+	required := false
+
+	for _, member := range members {
+		if member.Name == "" {
+			member.Name = member.Sink.Name()
+		}
+		required = required || member.Required
+
+		compiled := fanoutMember{FanoutMember: member}
+		for _, pattern := range member.Filter.EventTypes {
+			match, err := compileEventTypePattern(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid event type filter for sink %s: %w", member.Name, err)
+			}
+			compiled.eventTypes = append(compiled.eventTypes, match)
+		}
+		if len(member.Filter.Tenants) > 0 {
+			compiled.tenants = make(map[string]bool, len(member.Filter.Tenants))
+			for _, tenant := range member.Filter.Tenants {
+				compiled.tenants[tenant] = true
+			}
+		}
+
+		sink.members = append(sink.members, compiled)
+	}
+
+	if !required {
+		return nil, fmt.Errorf("at least one sink must be required")
+	}
+
+	sink.ctx, sink.cancel = context.WithCancel(context.Background())
+	return sink, nil
+}
+
+// accepts reports whether the member's filter selects the event
+func (m fanoutMember) accepts(event models.EnrichedEvent) bool {
+	if m.tenants != nil && !m.tenants[event.TenantID] {
+		return false
+	}
+	if len(m.eventTypes) == 0 {
+		return true
+	}
+	for _, match := range m.eventTypes {
+		if match(event.EventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the sink name
+func (s *FanoutSink) Name() string {
+	return SinkFanout
+}
+
+// PublishEvent hands the event to every member selecting it and fails if a required member
+// fails. Required members that fail are retried on their own, so members that already accepted
+// the event do not receive it twice; callers must not retry an ErrRetriesExhausted failure.
+func (s *FanoutSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	_, err := s.publish(ctx, event, false)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetriesExhausted, err)
+	}
+	return nil
+}
+
+// PublishEventSync waits until every required member selecting the event has stored it;
+// best-effort members receive it in the background. The report is the first such required member's,
+// and is empty when no required member selects the event.
+func (s *FanoutSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	return s.publish(ctx, event, true)
+}
+
+// publish sends the event to every member selecting it concurrently and waits for the
+// required ones; best-effort members finish in the background. Without confirm, failed
+// required members are retried up to fanoutPublishAttempts times.
+func (s *FanoutSink) publish(ctx context.Context, event models.EnrichedEvent, confirm bool) (DeliveryReport, error) {
+	reports := make([]DeliveryReport, len(s.members))
+	errs := make([]error, len(s.members))
+
+	var pending []int
+	for i, member := range s.members {
+		if !member.accepts(event) {
+			continue
+		}
+		if member.Required {
+			pending = append(pending, i)
+		} else {
+			s.publishInBackground(ctx, member, event)
+		}
+	}
+
+	attempts := fanoutPublishAttempts
+	if confirm {
+		attempts = 1
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		var wg sync.WaitGroup
+		for _, i := range pending {
+			wg.Add(1)
+			go func(i int, member fanoutMember) {
+				defer wg.Done()
+				if confirm {
+					reports[i], errs[i] = member.Sink.PublishEventSync(ctx, event)
+				} else {
+					errs[i] = member.Sink.PublishEvent(ctx, event)
+				}
+			}(i, s.members[i])
+		}
+		wg.Wait()
+
+		var failed []int
+		for _, i := range pending {
+			if !s.record(s.members[i], event.EventID, errs[i], attempt, attempts) {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 || attempt >= attempts {
+			break
+		}
+
+		// Same backoff the handler applies to single sinks
+		select {
+		case <-time.After(time.Duration(attempt*attempt) * 100 * time.Millisecond):
+		case <-ctx.Done():
+			return DeliveryReport{}, ctx.Err()
+		}
+	}
+
+	var report DeliveryReport
+	reported := false
+	var failures []error
+	for i, member := range s.members {
+		if !member.Required || !member.accepts(event) {
+			continue
+		}
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("sink %s: %w", member.Name, errs[i]))
+		} else if !reported {
+			report = reports[i]
+			reported = true
+		}
+	}
+
+	return report, errors.Join(failures...)
+}
+
+// publishInBackground hands the event to a best-effort member without making the caller wait.
+// The publish outlives the request, so it is only cancelled when Close gives up waiting for it.
+func (s *FanoutSink) publishInBackground(ctx context.Context, member fanoutMember, event models.EnrichedEvent) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		s.record(member, event.EventID, ErrFanoutClosed, 1, 1)
+		return
+	}
+	s.background.Add(1)
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.ctx, cancel)
+
+	go func() {
+		defer s.background.Done()
+		defer cancel()
+		defer stop()
+
+		s.record(member, event.EventID, member.Sink.PublishEvent(ctx, event), 1, 1)
+	}()
+}
+
+// record counts a member's publish outcome, logs failures and reports whether it succeeded
+func (s *FanoutSink) record(member fanoutMember, eventID string, err error, attempt, attempts int) bool {
+	if err == nil {
+		metrics.SinkPublishes.WithLabelValues(member.Name, "success").Inc()
+		return true
+	}
+
+	metrics.SinkPublishes.WithLabelValues(member.Name, "failure").Inc()
+	if member.Required {
+		s.logger.Warn("Required sink failed to publish event",
+			zap.String("sink", member.Name),
+			zap.String("event_id", eventID),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", attempts),
+			zap.Error(err),
+		)
+	} else {
+		s.logger.Warn("Best-effort sink failed to publish event",
+			zap.String("sink", member.Name),
+			zap.String("event_id", eventID),
+			zap.Error(err),
+		)
+	}
+	return false
+}
+
+// DeadLetterEnabled reports whether any member keeps dead letters
+func (s *FanoutSink) DeadLetterEnabled() bool {
+	for _, member := range s.members {
+		if member.Sink.DeadLetterEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxDeadLetterPayloadBytes returns the smallest limit of the members keeping dead letters
+func (s *FanoutSink) MaxDeadLetterPayloadBytes() int {
+	limit := 0
+	for _, member := range s.members {
+		if !member.Sink.DeadLetterEnabled() {
+			continue
+		}
+		if memberLimit := member.Sink.MaxDeadLetterPayloadBytes(); memberLimit > 0 && (limit == 0 || memberLimit < limit) {
+			limit = memberLimit
+		}
+	}
+	return limit
+}
+
+// PublishDeadLetter sends the envelope to every member keeping dead letters. Filters do
+// not apply, since rejected payloads may have no usable event type or tenant.
+func (s *FanoutSink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	var failures []error
+	for _, member := range s.members {
+		if !member.Sink.DeadLetterEnabled() {
+			continue
+		}
+		if err := member.Sink.PublishDeadLetter(ctx, envelope); err != nil {
+			failures = append(failures, fmt.Errorf("sink %s: %w", member.Name, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// HealthCheck reports the health of the required members
+func (s *FanoutSink) HealthCheck() error {
+	for _, member := range s.members {
+		if !member.Required {
+			continue
+		}
+		if err := member.Sink.HealthCheck(); err != nil {
+			return fmt.Errorf("sink %s: %w", member.Name, err)
+		}
+	}
+	return nil
+}
+
+// ReadinessCheck runs every member's checks, prefixed with the member name. Only
+// required members affect readiness.
+func (s *FanoutSink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	var results []models.HealthCheckResult
+	ready := true
+
+	for _, member := range s.members {
+		checks, memberReady := member.Sink.ReadinessCheck(ctx)
+		for _, check := range checks {
+			check.Name = member.Name + "." + check.Name
+			results = append(results, check)
+		}
+		if member.Required && !memberReady {
+			ready = false
+		}
+	}
+
+	return results, ready
+}
+
+// GetStats returns each member's statistics
+func (s *FanoutSink) GetStats() map[string]interface{} {
+	sinks := make(map[string]interface{}, len(s.members))
+	for _, member := range s.members {
+		stats := member.Sink.GetStats()
+		stats["required"] = member.Required
+		if len(member.Filter.EventTypes) > 0 {
+			stats["event_types"] = member.Filter.EventTypes
+		}
+		if len(member.Filter.Tenants) > 0 {
+			stats["tenants"] = member.Filter.Tenants
+		}
+		sinks[member.Name] = stats
+	}
+	return map[string]interface{}{"sinks": sinks}
+}
+
+// Close waits up to fanoutDrainTimeout for best-effort publishes still running, cancels the
+// rest, and closes every member
+func (s *FanoutSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(fanoutDrainTimeout):
+		s.logger.Warn("Timed out waiting for best-effort sinks, cancelling their publishes",
+			zap.Duration("timeout", fanoutDrainTimeout),
+		)
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+
+	var failures []error
+	for _, member := range s.members {
+		if err := member.Sink.Close(); err != nil {
+			failures = append(failures, fmt.Errorf("sink %s: %w", member.Name, err))
+		}
+	}
+	return errors.Join(failures...)
+}
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"ingestion-service/models"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// fileSinkSuffix names completed files; files still being written carry fileSinkPartSuffix too
+	fileSinkSuffix     = ".ndjson"
+	fileSinkPartSuffix = ".part"
+	fileSinkGzipSuffix = ".gz"
+
+	// fileSinkDeadLetterPartition holds dead-letter envelopes within each hour
+	fileSinkDeadLetterPartition = "_dead_letter"
+
+	// fileSinkBufferBytes is the write buffer size of each open file
+	fileSinkBufferBytes = 64 * 1024
+)
+
+// unsafePartitionChars are replaced in event types used as directory names
+var unsafePartitionChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// FileSinkConfig holds file sink configuration
+type FileSinkConfig struct {
+	// Dir is the root directory; files are written to Dir/dt=YYYY-MM-DD/hour=HH/event_type=NAME/
+	Dir string
+	// MaxBytes rotates a file once it reaches this size
+	MaxBytes int64
+	// MaxAge rotates a file once it has been open this long
+	MaxAge time.Duration
+	// Gzip compresses files once they are rotated
+	Gzip bool
+	// FlushInterval is how often buffered lines are written out and aged files rotated
+	FlushInterval time.Duration
+	// MaxOpenFiles caps the files open at once; opening another rotates the least recently written
+	MaxOpenFiles int
+}
+
+// FileSink writes events as newline-delimited JSON, partitioned by the hour they were
+// received and their event type. Files being written end in .ndjson.part and are renamed
+// to .ndjson (or compressed to .ndjson.gz) when rotated, so readers can skip open files.
+type FileSink struct {
+	config FileSinkConfig
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	files    map[string]*rollingFile
+	closed   bool
+	lastErr  error
+	written  int64
+	bytes    int64
+	rotated  int64
+	failures int64
+
+	compressing sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// rollingFile is an open file within a partition
+type rollingFile struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	bytes  int64
+	lines  int64
+	opened time.Time
+	used   time.Time
+}
+
+// NewFileSink creates the root directory, completes files left open by a previous run
+// and starts the background flusher
+func NewFileSink(config FileSinkConfig, logger *zap.Logger) (*FileSink, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create file sink directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := &FileSink{
+		config: config,
+		logger: logger,
+		files:  make(map[string]*rollingFile),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if err := sink.recoverPartFiles(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go sink.flushLoop()
+
+	return sink, nil
+}
+
+// Name returns the sink name
+func (s *FileSink) Name() string {
+	return SinkFile
+}
+
+// PublishEvent appends an event to its partition's file. The line is buffered and
+// written out within FlushInterval.
+func (s *FileSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	_, err := s.write(event.ProcessingInfo.ReceivedAt, event.EventType, event, false)
+	return err
+}
+
+// PublishEventSync appends an event and syncs it to disk. The report names the
+// file relative to Dir and the event's line number within it, counting from 0.
+func (s *FileSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	return s.write(event.ProcessingInfo.ReceivedAt, event.EventType, event, true)
+}
+
+// DeadLetterEnabled reports that dead letters are always written
+func (s *FileSink) DeadLetterEnabled() bool {
+	return true
+}
+
+// MaxDeadLetterPayloadBytes returns 0; payloads are kept whole
+func (s *FileSink) MaxDeadLetterPayloadBytes() int {
+	return 0
+}
+
+// PublishDeadLetter appends an envelope to the hour's dead-letter partition
+func (s *FileSink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	_, err := s.write(envelope.Timestamp, fileSinkDeadLetterPartition, envelope, false)
+	return err
+}
+
+// write appends value as a line to the file of the partition for receivedAt and name
+func (s *FileSink) write(receivedAt time.Time, name string, value interface{}, flush bool) (DeliveryReport, error) {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return DeliveryReport{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	partition := partitionDir(receivedAt, name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return DeliveryReport{}, fmt.Errorf("file sink is closed")
+	}
+
+	file := s.files[partition]
+	if file != nil && file.bytes > 0 && file.bytes+int64(len(line)) > s.config.MaxBytes {
+		s.rotate(partition)
+		file = nil
+	}
+	if file == nil {
+		// Every event type opens its own file, so clients sending many types must not exhaust descriptors
+		if s.config.MaxOpenFiles > 0 && len(s.files) >= s.config.MaxOpenFiles {
+			s.rotate(s.leastRecentlyUsed())
+		}
+		if file, err = s.open(partition); err != nil {
+			return DeliveryReport{}, s.fail(err)
+		}
+		s.files[partition] = file
+	}
+
+	// A failed buffer keeps failing, so the file is completed and the next write starts a new one
+	if _, err := file.writer.Write(line); err != nil {
+		s.rotate(partition)
+		return DeliveryReport{}, s.fail(fmt.Errorf("failed to write %s: %w", file.path, err))
+	}
+	if flush {
+		if err := file.writer.Flush(); err != nil {
+			s.rotate(partition)
+			return DeliveryReport{}, s.fail(fmt.Errorf("failed to flush %s: %w", file.path, err))
+		}
+		// The ack promises the event survives a crash, not just that it left the buffer
+		if err := file.file.Sync(); err != nil {
+			s.rotate(partition)
+			return DeliveryReport{}, s.fail(fmt.Errorf("failed to sync %s: %w", file.path, err))
+		}
+	}
+
+	report := DeliveryReport{Topic: s.completedName(file.path), Offset: file.lines}
+	file.bytes += int64(len(line))
+	file.lines++
+	file.used = time.Now()
+	s.written++
+	s.bytes += int64(len(line))
+	s.lastErr = nil
+
+	return report, nil
+}
+
+// open creates a new file in the partition directory. Callers must hold s.mu.
+func (s *FileSink) open(partition string) (*rollingFile, error) {
+	dir := filepath.Join(s.config.Dir, partition)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create partition directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("events-%s-%09d%s%s", now.Format("20060102T150405"), now.Nanosecond(), fileSinkSuffix, fileSinkPartSuffix)
+	path := filepath.Join(dir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	return &rollingFile{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriterSize(file, fileSinkBufferBytes),
+		opened: now,
+		used:   now,
+	}, nil
+}
+
+// leastRecentlyUsed returns the partition whose file was written longest ago. Callers must hold s.mu.
+func (s *FileSink) leastRecentlyUsed() string {
+	var oldest string
+	var used time.Time
+	for partition, file := range s.files {
+		if oldest == "" || file.used.Before(used) {
+			oldest, used = partition, file.used
+		}
+	}
+	return oldest
+}
+
+// rotate closes the partition's file and completes it. Callers must hold s.mu.
+func (s *FileSink) rotate(partition string) {
+	file := s.files[partition]
+	delete(s.files, partition)
+
+	if err := file.close(); err != nil {
+		s.fail(err)
+		s.logger.Error("Failed to close file sink file", zap.String("path", file.path), zap.Error(err))
+	}
+	s.rotated++
+
+	s.complete(file.path)
+}
+
+// complete renames a .part file to its final name, compressing it in the background
+// when Gzip is set
+func (s *FileSink) complete(partPath string) {
+	path := strings.TrimSuffix(partPath, fileSinkPartSuffix)
+	if !s.config.Gzip {
+		if err := os.Rename(partPath, path); err != nil {
+			s.logger.Error("Failed to complete file sink file", zap.String("path", partPath), zap.Error(err))
+		}
+		return
+	}
+
+	s.compressing.Add(1)
+	go func() {
+		defer s.compressing.Done()
+		if err := gzipFile(partPath, path+fileSinkGzipSuffix); err != nil {
+			s.logger.Error("Failed to compress file sink file, keeping it uncompressed",
+				zap.String("path", partPath),
+				zap.Error(err),
+			)
+			if err := os.Rename(partPath, path); err != nil {
+				s.logger.Error("Failed to complete file sink file", zap.String("path", partPath), zap.Error(err))
+			}
+		}
+	}()
+}
+
+// completedName returns the name a file will have once completed, relative to Dir
+func (s *FileSink) completedName(partPath string) string {
+	name := strings.TrimSuffix(partPath, fileSinkPartSuffix)
+	if s.config.Gzip {
+		name += fileSinkGzipSuffix
+	}
+	if rel, err := filepath.Rel(s.config.Dir, name); err == nil {
+		return rel
+	}
+	return name
+}
+
+// fail records a write error. Callers must hold s.mu.
+func (s *FileSink) fail(err error) error {
+	s.lastErr = err
+	s.failures++
+	return err
+}
+
+// flushLoop writes out buffered lines and rotates files past MaxAge
+func (s *FileSink) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush writes out every buffer and rotates aged files
+func (s *FileSink) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for partition, file := range s.files {
+		if time.Since(file.opened) >= s.config.MaxAge {
+			s.rotate(partition)
+			continue
+		}
+		if err := file.writer.Flush(); err != nil {
+			s.fail(err)
+			s.logger.Error("Failed to flush file sink file", zap.String("path", file.path), zap.Error(err))
+			s.rotate(partition)
+		}
+	}
+}
+
+// recoverPartFiles completes files left open by a previous run. Compressed files still
+// being written when it stopped are incomplete; their source is only removed once they
+// are, so they are deleted and the source is compressed again.
+func (s *FileSink) recoverPartFiles() error {
+	var partFiles, gzipParts []string
+	err := filepath.WalkDir(s.config.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+		case strings.HasSuffix(path, fileSinkSuffix+fileSinkPartSuffix):
+			partFiles = append(partFiles, path)
+		case strings.HasSuffix(path, fileSinkSuffix+fileSinkGzipSuffix+fileSinkPartSuffix):
+			gzipParts = append(gzipParts, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan file sink directory: %w", err)
+	}
+
+	// Remove the stale temporaries before completing, which writes new ones at the same paths
+	for _, path := range gzipParts {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove incomplete file %s: %w", path, err)
+		}
+	}
+	for _, path := range partFiles {
+		s.complete(path)
+	}
+
+	if len(partFiles) > 0 || len(gzipParts) > 0 {
+		s.logger.Warn("Completed file sink files left open by a previous run",
+			zap.String("dir", s.config.Dir),
+			zap.Int("files", len(partFiles)),
+			zap.Int("incomplete_gzip_removed", len(gzipParts)),
+		)
+	}
+	return nil
+}
+
+// HealthCheck reports the most recent write error, if the last write failed
+func (s *FileSink) HealthCheck() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("file sink is closed")
+	}
+	return s.lastErr
+}
+
+// ReadinessCheck verifies the directory is writable and the last write succeeded
+func (s *FileSink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	if err := s.HealthCheck(); err != nil {
+		return []models.HealthCheckResult{{Name: "file_sink", Status: "fail", Message: err.Error()}}, false
+	}
+
+	probe, err := os.CreateTemp(s.config.Dir, ".readiness-")
+	if err != nil {
+		return []models.HealthCheckResult{{Name: "file_sink", Status: "fail", Message: err.Error()}}, false
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return []models.HealthCheckResult{{Name: "file_sink", Status: "pass"}}, true
+}
+
+// GetStats returns file sink statistics
+func (s *FileSink) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"status":         "active",
+		"dir":            s.config.Dir,
+		"open_files":     len(s.files),
+		"max_open_files": s.config.MaxOpenFiles,
+		"events_written": s.written,
+		"bytes_written":  s.bytes,
+		"files_rotated":  s.rotated,
+		"write_errors":   s.failures,
+		"gzip":           s.config.Gzip,
+		"max_bytes":      s.config.MaxBytes,
+		"max_age_ms":     s.config.MaxAge.Milliseconds(),
+	}
+}
+
+// Close flushes and completes every open file and waits for compression to finish
+func (s *FileSink) Close() error {
+	s.logger.Info("Shutting down file sink")
+
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	s.closed = true
+	for partition := range s.files {
+		s.rotate(partition)
+	}
+	err := s.lastErr
+	s.mu.Unlock()
+
+	s.compressing.Wait()
+	return err
+}
+
+// close flushes and closes the file
+func (f *rollingFile) close() error {
+	if err := f.writer.Flush(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("failed to flush %s: %w", f.path, err)
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// partitionDir returns the directory of the partition for the hour and name
+func partitionDir(receivedAt time.Time, name string) string {
+	receivedAt = receivedAt.UTC()
+	if name != fileSinkDeadLetterPartition {
+		name = unsafePartitionChars.ReplaceAllString(name, "_")
+		if name == "" || name == "." || name == ".." {
+			name = "unknown"
+		}
+		name = "event_type=" + name
+	}
+	return filepath.Join(
+		"dt="+receivedAt.Format("2006-01-02"),
+		"hour="+receivedAt.Format("15"),
+		name,
+	)
+}
+
+// gzipFile compresses src into dst and removes src
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + fileSinkPartSuffix
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(out)
+	if _, err := io.Copy(writer, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// The source is removed below, so the compressed copy must be on disk first
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFileSinkRecoversPartFiles(t *testing.T) {
+	dir := t.TempDir()
+	partition := filepath.Join(dir, "dt=2026-10-16", "hour=11", "event_type=page_view")
+	if err := os.MkdirAll(partition, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	// A crash during compression leaves the source and a half-written gzip next to it
+	base := filepath.Join(partition, "events-20261016T110502-000000001"+fileSinkSuffix)
+	if err := os.WriteFile(base+fileSinkPartSuffix, []byte("{\"n\":1}\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(base+fileSinkGzipSuffix+fileSinkPartSuffix, []byte("\x1f\x8b"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	sink, err := NewFileSink(FileSinkConfig{
+		Dir:           dir,
+		MaxBytes:      1024,
+		MaxAge:        time.Hour,
+		Gzip:          true,
+		FlushInterval: time.Second,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(partition, "*"+fileSinkPartSuffix))
+	if len(matches) != 0 {
+		t.Errorf("part files left after recovery: %v", matches)
+	}
+
+	file, err := os.Open(base + fileSinkGzipSuffix)
+	if err != nil {
+		t.Fatalf("opening completed file: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading completed file: %v", err)
+	}
+	if string(content) != "{\"n\":1}\n" {
+		t.Errorf("content = %q, want the recovered line", content)
+	}
+}
+
+func TestFileSinkMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(FileSinkConfig{
+		Dir:           dir,
+		MaxBytes:      1024,
+		MaxAge:        time.Hour,
+		FlushInterval: time.Hour,
+		MaxOpenFiles:  2,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	receivedAt := time.Date(2026, 10, 16, 11, 5, 0, 0, time.UTC)
+	for _, name := range []string{"page_view", "click", "page_view", "signup"} {
+		if _, err := sink.write(receivedAt, name, map[string]string{"event_type": name}, false); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	if open := sink.GetStats()["open_files"]; open != 2 {
+		t.Errorf("open_files = %v, want 2", open)
+	}
+
+	// click was written least recently, so opening signup completed it
+	for name, want := range map[string]string{
+		"click":     "*" + fileSinkSuffix,
+		"page_view": "*" + fileSinkSuffix + fileSinkPartSuffix,
+		"signup":    "*" + fileSinkSuffix + fileSinkPartSuffix,
+	} {
+		matches, _ := filepath.Glob(filepath.Join(dir, partitionDir(receivedAt, name), want))
+		if len(matches) != 1 {
+			t.Errorf("%s files matching %s = %v, want one", name, want, matches)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"ingestion-service/models"
+)
+
+// messageHeader is a header set on NATS messages and Redis stream entries
+type messageHeader struct {
+	key   string
+	value string
+}
+
+// eventHeaders returns the same metadata the Kafka sink sets as record headers, in a
+// stable order and without empty values
+func eventHeaders(ctx context.Context, event models.EnrichedEvent) []messageHeader {
+	requestID := requestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = event.RequestID
+	}
+
+	headers := []messageHeader{
+		{HeaderContentType, "application/json"},
+		{HeaderRequestID, requestID},
+		{HeaderEventType, event.EventType},
+		{HeaderSchemaVersion, event.SchemaVersion},
+		{HeaderProject, event.ProjectID},
+		{HeaderTenant, event.TenantID},
+	}
+	if event.TraceContext != nil {
+		headers = append(headers,
+			messageHeader{HeaderTraceParent, event.TraceContext.TraceParent},
+			messageHeader{HeaderTraceState, event.TraceContext.TraceState},
+		)
+	}
+
+	return nonEmptyHeaders(headers)
+}
+
+// deadLetterHeaders returns the headers set on a dead-letter envelope
+func deadLetterHeaders(ctx context.Context, envelope models.DeadLetterEnvelope) []messageHeader {
+	requestID := requestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = envelope.RequestID
+	}
+
+	return nonEmptyHeaders([]messageHeader{
+		{HeaderContentType, "application/json"},
+		{HeaderRequestID, requestID},
+		{HeaderErrorCode, envelope.ErrorCode},
+	})
+}
+
+// nonEmptyHeaders drops headers without a value
+func nonEmptyHeaders(headers []messageHeader) []messageHeader {
+	kept := headers[:0]
+	for _, header := range headers {
+		if header.value != "" {
+			kept = append(kept, header)
+		}
+	}
+	return kept
+}
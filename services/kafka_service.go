@@ -3,39 +3,122 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 	"go.uber.org/zap"
 )
 
+const (
+	// spoolReplayBatchSize is the number of spooled messages redelivered per round trip
+	spoolReplayBatchSize = 500
+
+	// metadataCheckTTL is how long a broker metadata check result is reused by readiness probes
+	metadataCheckTTL = 5 * time.Second
+)
+
 // KafkaService handles Kafka producer operations
 type KafkaService struct {
-	producer sarama.AsyncProducer
-	config   KafkaConfig
-	logger   *zap.Logger
-	ctx      context.Context
-	cancel   context.CancelFunc
+	client     sarama.Client
+	producer   sarama.AsyncProducer
+	deliveries *deliveryTracker
+	router     *TopicRouter
+	keys       *PartitionKeyStrategy
+	spool      *Spool
+	config     KafkaConfig
+	logger     *zap.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// closing is closed when Close starts, so no message is sent once the producer closes;
+	// inputMu is held for reading while a message is being sent
+	closing       chan struct{}
+	inputMu       sync.RWMutex
+	handlers      sync.WaitGroup
+	replaying     sync.WaitGroup
+	closeFailures int
+
+	metadataMu      sync.Mutex
+	metadataChecked time.Time
+	metadataErr     error
 }
 
 // KafkaConfig holds Kafka configuration
 type KafkaConfig struct {
 	Brokers         []string
 	Topic           string
+	TopicRoutes     []TopicRoute
+	PartitionKey    string
 	Acks            string
 	Retries         int
 	BatchSize       int
 	LingerMs        int
 	Compression     string
 	MaxMessageBytes int
+	DeliveryTimeout time.Duration
+
+	// Spool settings; the spool is disabled when SpoolDir is empty
+	SpoolDir            string
+	SpoolMaxBytes       int64
+	SpoolReplayInterval time.Duration
+
+	// DeadLetterTopic receives invalid and undeliverable events; disabled when empty
+	DeadLetterTopic string
+
+	// Readiness settings: the service is not ready when the delivery error rate over
+	// ReadinessErrorWindow exceeds ReadinessMaxErrorRate with at least ReadinessMinSamples outcomes
+	ReadinessErrorWindow  time.Duration
+	ReadinessMaxErrorRate float64
+	ReadinessMinSamples   int
+	ReadinessTimeout      time.Duration
+}
+
+// DeliveryReport describes where the broker stored an acknowledged message
+type DeliveryReport struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	// ID identifies the message on brokers without offsets
+	ID string
+}
+
+// deliveryResult carries the broker outcome for a message awaiting acknowledgement
+type deliveryResult struct {
+	report DeliveryReport
+	err    error
 }
 
+// messageMetadata is attached to producer messages to route broker outcomes back to callers
+type messageMetadata struct {
+	requestID  string
+	deadLetter bool
+	result     chan deliveryResult
+}
+
+// Record header names set on messages so consumers can filter without decoding the body
+const (
+	HeaderContentType   = "content_type"
+	HeaderRequestID     = "request_id"
+	HeaderEventType     = "event_type"
+	HeaderSchemaVersion = "schema_version"
+	HeaderProject       = "project"
+	HeaderTenant        = "tenant"
+	HeaderTraceParent   = "traceparent"
+	HeaderTraceState    = "tracestate"
+	HeaderErrorCode     = "error_code"
+)
+
 // Message represents a Kafka message
 type Message struct {
-	Key   []byte
-	Value []byte
-	Topic string
+	Key     []byte            `json:"key"`
+	Value   []byte            `json:"value"`
+	Topic   string            `json:"topic"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // NewKafkaService creates a new Kafka service instance
@@ -43,21 +126,58 @@ func NewKafkaService(config KafkaConfig, logger *zap.Logger) (*KafkaService, err
 	ctx, cancel := context.WithCancel(context.Background())
 
 	service := &KafkaService{
-		config: config,
-		logger: logger,
-		ctx:    ctx,
-		cancel: cancel,
+		config:     config,
+		logger:     logger,
+		ctx:        ctx,
+		cancel:     cancel,
+		closing:    make(chan struct{}),
+		deliveries: newDeliveryTracker(config.ReadinessErrorWindow),
+	}
+
+	router, err := NewTopicRouter(config.TopicRoutes, config.Topic)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize topic routes: %w", err)
+	}
+	service.router = router
+
+	keys, err := NewPartitionKeyStrategy(config.PartitionKey)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize partition key strategy: %w", err)
+	}
+	service.keys = keys
+
+	if config.SpoolDir != "" {
+		spool, err := NewSpool(SpoolConfig{
+			Dir:      config.SpoolDir,
+			MaxBytes: config.SpoolMaxBytes,
+		}, logger)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to initialize spool: %w", err)
+		}
+		service.spool = spool
 	}
 
 	if err := service.initializeProducer(); err != nil {
 		cancel()
+		if service.spool != nil {
+			service.spool.Close()
+		}
 		return nil, fmt.Errorf("failed to initialize Kafka producer: %w", err)
 	}
 
 	// Start error handling goroutine
+	service.handlers.Add(2)
 	go service.handleErrors()
 	go service.handleSuccesses()
 
+	if service.spool != nil {
+		service.replaying.Add(1)
+		go service.replaySpool()
+	}
+
 	return service, nil
 }
 
@@ -105,16 +225,28 @@ func (ks *KafkaService) initializeProducer() error {
 	// Set message size limit
 	config.Producer.MaxMessageBytes = ks.config.MaxMessageBytes
 
+	// Report both outcomes so delivery-confirmed publishes can be resolved
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+
 	// Enable idempotent producer for exactly-once semantics
 	config.Producer.Idempotent = true
 	config.Net.MaxOpenRequests = 1
 
+	// Create a client shared by the producer and the readiness checks
+	client, err := sarama.NewClient(ks.config.Brokers, config)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
 	// Create async producer
-	producer, err := sarama.NewAsyncProducer(ks.config.Brokers, config)
+	producer, err := sarama.NewAsyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
 		return fmt.Errorf("failed to create Kafka producer: %w", err)
 	}
 
+	ks.client = client
 	ks.producer = producer
 	ks.logger.Info("Kafka producer initialized successfully",
 		zap.Strings("brokers", ks.config.Brokers),
@@ -126,88 +258,502 @@ func (ks *KafkaService) initializeProducer() error {
 
 // PublishMessage sends a message to Kafka
 func (ks *KafkaService) PublishMessage(ctx context.Context, key string, value interface{}) error {
-	select {
+	message, err := ks.newProducerMessage(ctx, ks.config.Topic, key, value)
+	if err != nil {
+		return err
+	}
+
+	return ks.enqueue(ctx, message)
+}
+
+// PublishMessageSync sends a message to Kafka and waits for the broker acknowledgement
+func (ks *KafkaService) PublishMessageSync(ctx context.Context, key string, value interface{}) (DeliveryReport, error) {
+	message, err := ks.newProducerMessage(ctx, ks.config.Topic, key, value)
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+
+	return ks.deliverOne(ctx, message)
+}
+
+// deliverOne enqueues a single message and waits for its broker acknowledgement
+func (ks *KafkaService) deliverOne(ctx context.Context, message *sarama.ProducerMessage) (DeliveryReport, error) {
+	reports, err := ks.deliver(ctx, []*sarama.ProducerMessage{message})
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+	return reports[0], nil
+}
+
+// deliver enqueues messages and waits until the broker acknowledges all of them
+func (ks *KafkaService) deliver(ctx context.Context, messages []*sarama.ProducerMessage) ([]DeliveryReport, error) {
+	reports, errs := ks.deliverEach(ctx, messages)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+// deliverEach enqueues messages and waits for the broker outcome of each one, returning a
+// report and an error per message so callers can tell delivered messages from failed ones
+func (ks *KafkaService) deliverEach(ctx context.Context, messages []*sarama.ProducerMessage) ([]DeliveryReport, []error) {
+	if ks.config.DeliveryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ks.config.DeliveryTimeout)
+		defer cancel()
+	}
+
+	reports := make([]DeliveryReport, len(messages))
+	errs := make([]error, len(messages))
+	pending := make([]*messageMetadata, len(messages))
+	for i, message := range messages {
+		metadata, ok := message.Metadata.(*messageMetadata)
+		if !ok {
+			metadata = &messageMetadata{}
+			message.Metadata = metadata
+		}
+		metadata.result = make(chan deliveryResult, 1)
+
+		if err := ks.enqueue(ctx, message); err != nil {
+			for j := i; j < len(messages); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		pending[i] = metadata
+	}
+
+	for i, metadata := range pending {
+		if metadata == nil {
+			continue
+		}
+
+		select {
+		case result := <-metadata.result:
+			reports[i], errs[i] = result.report, result.err
+		case <-ctx.Done():
+			errs[i] = fmt.Errorf("timed out waiting for Kafka delivery confirmation: %w", ctx.Err())
 		case <-ks.ctx.Done():
-			return fmt.Errorf("kafka service is shutting down")
-		default:
+			errs[i] = fmt.Errorf("kafka service is shutting down")
+		}
 	}
 
+	return reports, errs
+}
+
+// newProducerMessage serializes the value and builds a producer message
+func (ks *KafkaService) newProducerMessage(ctx context.Context, topic, key string, value interface{}) (*sarama.ProducerMessage, error) {
 	// Serialize value to JSON
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message value: %w", err)
+		return nil, fmt.Errorf("failed to marshal message value: %w", err)
+	}
+
+	requestID := requestIDFromContext(ctx)
+	headers := []sarama.RecordHeader{{Key: []byte(HeaderContentType), Value: []byte("application/json")}}
+	if requestID != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderRequestID), Value: []byte(requestID)})
 	}
 
 	// Create Kafka message
-	message := &sarama.ProducerMessage{
-		Topic:     ks.config.Topic,
+	return &sarama.ProducerMessage{
+		Topic:     topic,
 		Key:       sarama.StringEncoder(key),
 		Value:     sarama.ByteEncoder(jsonValue),
+		Headers:   headers,
 		Timestamp: time.Now(),
+		Metadata:  &messageMetadata{requestID: requestID},
+	}, nil
+}
+
+// newEventMessage builds a producer message for an event, with its metadata as record headers
+func (ks *KafkaService) newEventMessage(ctx context.Context, event models.EnrichedEvent) (*sarama.ProducerMessage, error) {
+	message, err := ks.newProducerMessage(ctx, ks.router.Resolve(event.EventType), ks.keys.Key(event), event)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestIDFromContext(ctx) == "" && event.RequestID != "" {
+		message.Headers = appendHeader(message.Headers, HeaderRequestID, event.RequestID)
+	}
+	message.Headers = appendHeader(message.Headers, HeaderEventType, event.EventType)
+	message.Headers = appendHeader(message.Headers, HeaderSchemaVersion, event.SchemaVersion)
+	message.Headers = appendHeader(message.Headers, HeaderProject, event.ProjectID)
+	message.Headers = appendHeader(message.Headers, HeaderTenant, event.TenantID)
+	if event.TraceContext != nil {
+		message.Headers = appendHeader(message.Headers, HeaderTraceParent, event.TraceContext.TraceParent)
+		message.Headers = appendHeader(message.Headers, HeaderTraceState, event.TraceContext.TraceState)
+	}
+
+	return message, nil
+}
+
+// appendHeader adds a record header, skipping empty values
+func appendHeader(headers []sarama.RecordHeader, key, value string) []sarama.RecordHeader {
+	if value == "" {
+		return headers
+	}
+	return append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// requestIDFromContext returns the request ID stored in the context by the handlers
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value("request_id").(string)
+	return requestID
+}
+
+// enqueue hands a message to the async producer
+func (ks *KafkaService) enqueue(ctx context.Context, message *sarama.ProducerMessage) error {
+	// Close waits for sends in progress before closing the producer's input channel
+	ks.inputMu.RLock()
+	defer ks.inputMu.RUnlock()
+
+	if ks.isClosing() {
+		return fmt.Errorf("kafka service is shutting down")
 	}
 
 	// Send message asynchronously
+	start := time.Now()
 	select {
 		case ks.producer.Input() <- message:
+			metrics.KafkaEnqueueDuration.Observe(time.Since(start).Seconds())
+			metrics.KafkaInFlight.Inc()
+			keyBytes, _ := message.Key.Encode()
 			ks.logger.Debug("Message sent to Kafka",
-				zap.String("topic", ks.config.Topic),
-				zap.String("key", key),
-				zap.Int("size", len(jsonValue)),
+				zap.String("topic", message.Topic),
+				zap.String("key", string(keyBytes)),
+				zap.Int("size", message.Value.Length()),
 			)
 			return nil
 		case <-ctx.Done():
 			return fmt.Errorf("context cancelled while sending message")
-		case <-ks.ctx.Done():
+		case <-ks.closing:
 			return fmt.Errorf("kafka service is shutting down")
 	}
 }
 
-// PublishEvent publishes an event to Kafka with proper error handling
-func (ks *KafkaService) PublishEvent(ctx context.Context, event interface{}, eventID string) error {
-	return ks.PublishMessage(ctx, eventID, event)
+// isClosing reports whether Close has started
+func (ks *KafkaService) isClosing() bool {
+	select {
+	case <-ks.closing:
+		return true
+	default:
+		return false
+	}
 }
 
-// handleErrors processes Kafka producer errors
-func (ks *KafkaService) handleErrors() {
-	for {
-		select {
-		case err := <-ks.producer.Errors():
-			keyBytes, _ := err.Msg.Key.Encode()
-			ks.logger.Error("Kafka producer error",
-				zap.Error(err),
-				zap.String("topic", err.Msg.Topic),
-				zap.String("key", string(keyBytes)),
-			)
-		case <-ks.ctx.Done():
+// Name returns the sink name
+func (ks *KafkaService) Name() string {
+	return SinkKafka
+}
+
+// PublishEvent publishes an event to the topic routed for its event type
+func (ks *KafkaService) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	message, err := ks.newEventMessage(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return ks.enqueue(ctx, message)
+}
+
+// PublishEventSync publishes an event and waits until the broker acknowledges it
+func (ks *KafkaService) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	message, err := ks.newEventMessage(ctx, event)
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+
+	return ks.deliverOne(ctx, message)
+}
+
+// ResolveTopic returns the topic an event type is routed to
+func (ks *KafkaService) ResolveTopic(eventType string) string {
+	return ks.router.Resolve(eventType)
+}
+
+// DeadLetterEnabled reports whether a dead-letter topic is configured
+func (ks *KafkaService) DeadLetterEnabled() bool {
+	return ks.config.DeadLetterTopic != ""
+}
+
+// MaxDeadLetterPayloadBytes returns the largest raw payload that fits in a dead-letter envelope
+func (ks *KafkaService) MaxDeadLetterPayloadBytes() int {
+	return ks.config.MaxMessageBytes / 2
+}
+
+// PublishDeadLetter publishes an envelope to the dead-letter topic
+func (ks *KafkaService) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	if !ks.DeadLetterEnabled() {
+		return nil
+	}
+
+	message, err := ks.newProducerMessage(ctx, ks.config.DeadLetterTopic, envelope.RequestID, envelope)
+	if err != nil {
+		return err
+	}
+
+	if requestIDFromContext(ctx) == "" {
+		message.Headers = appendHeader(message.Headers, HeaderRequestID, envelope.RequestID)
+	}
+	message.Headers = appendHeader(message.Headers, HeaderErrorCode, envelope.ErrorCode)
+	message.Metadata = &messageMetadata{requestID: envelope.RequestID, deadLetter: true}
+
+	return ks.enqueue(ctx, message)
+}
+
+// notifyDelivery resolves a pending delivery-confirmed publish and reports whether one was waiting
+func notifyDelivery(msg *sarama.ProducerMessage, result deliveryResult) bool {
+	if metadata, ok := msg.Metadata.(*messageMetadata); ok && metadata.result != nil {
+		metadata.result <- result
+		return true
+	}
+	return false
+}
+
+// handleUndelivered decides what happens to a message the producer gave up on. Callers waiting
+// for a delivery confirmation get the failure; everything else was already acknowledged to the
+// client, so transient failures go to the spool and permanent ones to the dead-letter topic.
+func (ks *KafkaService) handleUndelivered(producerErr *sarama.ProducerError, allowDeadLetter bool) {
+	if notifyDelivery(producerErr.Msg, deliveryResult{err: producerErr.Err}) {
+		return
+	}
+
+	metadata, _ := producerErr.Msg.Metadata.(*messageMetadata)
+	if metadata != nil && metadata.deadLetter {
+		// Never dead-letter a dead letter
+		return
+	}
+
+	if ks.spool != nil && !isPermanentDeliveryError(producerErr.Err) {
+		if ks.spoolMessage(producerErr.Msg) {
 			return
 		}
 	}
+
+	if allowDeadLetter {
+		ks.deadLetterUndelivered(producerErr.Msg, producerErr.Err)
+	}
 }
 
-// handleSuccesses processes successful Kafka messages
-func (ks *KafkaService) handleSuccesses() {
+// deadLetterUndelivered publishes a message the brokers refused to the dead-letter topic and
+// reports whether it was handed to the producer
+func (ks *KafkaService) deadLetterUndelivered(msg *sarama.ProducerMessage, deliveryErr error) bool {
+	if !ks.DeadLetterEnabled() {
+		return false
+	}
+
+	requestID := ""
+	if metadata, ok := msg.Metadata.(*messageMetadata); ok {
+		requestID = metadata.requestID
+	}
+
+	var value []byte
+	if msg.Value != nil {
+		value, _ = msg.Value.Encode()
+	}
+
+	envelope := models.NewDeadLetterEnvelope(value, "KAFKA_DELIVERY_FAILED", deliveryErr.Error(), requestID, ks.MaxDeadLetterPayloadBytes())
+	envelope.SourceTopic = msg.Topic
+	if err := ks.PublishDeadLetter(ks.ctx, envelope); err != nil {
+		ks.logger.Error("Failed to publish undeliverable message to dead-letter topic",
+			zap.String("topic", msg.Topic),
+			zap.String("request_id", requestID),
+			zap.Error(err),
+		)
+		return false
+	}
+	return true
+}
+
+// isPermanentDeliveryError reports whether retrying the message later cannot succeed
+func isPermanentDeliveryError(err error) bool {
+	var configErr sarama.ConfigurationError
+	if errors.As(err, &configErr) {
+		return true
+	}
+
+	for _, permanent := range []error{
+		sarama.ErrMessageSizeTooLarge,
+		sarama.ErrInvalidMessage,
+		sarama.ErrInvalidMessageSize,
+		sarama.ErrInvalidTopic,
+		sarama.ErrInvalidRecord,
+		sarama.ErrInvalidTimestamp,
+		sarama.ErrTopicAuthorizationFailed,
+	} {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// spoolMessage writes an undeliverable message to the disk spool and reports whether it was kept
+func (ks *KafkaService) spoolMessage(msg *sarama.ProducerMessage) bool {
+	if ks.spool == nil {
+		return false
+	}
+
+	var key, value []byte
+	if msg.Key != nil {
+		key, _ = msg.Key.Encode()
+	}
+	if msg.Value != nil {
+		value, _ = msg.Value.Encode()
+	}
+
+	headers := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+
+	if err := ks.spool.Append(Message{Key: key, Value: value, Topic: msg.Topic, Headers: headers}); err != nil {
+		ks.logger.Error("Failed to spool undeliverable message",
+			zap.String("topic", msg.Topic),
+			zap.String("key", string(key)),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	ks.logger.Warn("Spooled undeliverable message to disk",
+		zap.String("topic", msg.Topic),
+		zap.String("key", string(key)),
+	)
+	return true
+}
+
+// replaySpool periodically drains spooled messages back to Kafka once brokers recover
+func (ks *KafkaService) replaySpool() {
+	defer ks.replaying.Done()
+
+	ticker := time.NewTicker(ks.config.SpoolReplayInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case msg := <-ks.producer.Successes():
-			keyBytes, _ := msg.Key.Encode()
-			ks.logger.Debug("Message successfully sent to Kafka",
-				zap.String("topic", msg.Topic),
-				zap.Int32("partition", msg.Partition),
-				zap.Int64("offset", msg.Offset),
-				zap.String("key", string(keyBytes)),
+		case <-ticker.C:
+			if records, _ := ks.spool.Depth(); records == 0 {
+				continue
+			}
+
+			replayed, err := ks.spool.Replay(spoolReplayBatchSize, ks.replaySpooled)
+
+			records, _ := ks.spool.Depth()
+			if err != nil {
+				ks.logger.Warn("Spool replay interrupted, will retry",
+					zap.Int("replayed", replayed),
+					zap.Int64("remaining", records),
+					zap.Error(err),
+				)
+				continue
+			}
+
+			ks.logger.Info("Spool replay completed",
+				zap.Int("replayed", replayed),
+				zap.Int64("remaining", records),
 			)
-		case <-ks.ctx.Done():
+		case <-ks.closing:
 			return
 		}
 	}
 }
 
+// replaySpooled redelivers a chunk of spooled messages. Messages the brokers refuse for good
+// are dead-lettered or dropped instead of being kept, so they cannot block the rest of the spool.
+func (ks *KafkaService) replaySpooled(messages []Message) []error {
+	producerMessages := make([]*sarama.ProducerMessage, len(messages))
+	for i, message := range messages {
+		producerMessages[i] = &sarama.ProducerMessage{
+			Topic:     message.Topic,
+			Key:       sarama.ByteEncoder(message.Key),
+			Value:     sarama.ByteEncoder(message.Value),
+			Timestamp: time.Now(),
+			Metadata:  &messageMetadata{requestID: message.Headers[HeaderRequestID]},
+		}
+		for key, value := range message.Headers {
+			producerMessages[i].Headers = appendHeader(producerMessages[i].Headers, key, value)
+		}
+	}
+
+	_, errs := ks.deliverEach(ks.ctx, producerMessages)
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			metrics.SpoolRecords.WithLabelValues("replayed").Inc()
+		case isPermanentDeliveryError(err):
+			result := "dropped"
+			if ks.deadLetterUndelivered(producerMessages[i], err) {
+				result = "dead_lettered"
+			}
+			metrics.SpoolRecords.WithLabelValues(result).Inc()
+			ks.logger.Error("Spooled message permanently rejected by Kafka",
+				zap.String("topic", messages[i].Topic),
+				zap.String("key", string(messages[i].Key)),
+				zap.String("result", result),
+				zap.Error(err),
+			)
+			errs[i] = nil
+		}
+	}
+	return errs
+}
+
+// handleErrors processes Kafka producer errors until the producer closes its error channel
+func (ks *KafkaService) handleErrors() {
+	defer ks.handlers.Done()
+
+	for err := range ks.producer.Errors() {
+		metrics.KafkaInFlight.Dec()
+		metrics.KafkaDeliveries.WithLabelValues(err.Msg.Topic, "failure").Inc()
+		ks.deliveries.record(false)
+		keyBytes, _ := err.Msg.Key.Encode()
+		ks.logger.Error("Kafka producer error",
+			zap.Error(err),
+			zap.String("topic", err.Msg.Topic),
+			zap.String("key", string(keyBytes)),
+		)
+
+		// The producer no longer accepts dead letters once Close has started
+		closing := ks.isClosing()
+		if closing {
+			ks.closeFailures++
+		}
+		ks.handleUndelivered(err, !closing)
+	}
+}
+
+// handleSuccesses processes successful Kafka messages until the producer closes its success channel
+func (ks *KafkaService) handleSuccesses() {
+	defer ks.handlers.Done()
+
+	for msg := range ks.producer.Successes() {
+		metrics.KafkaInFlight.Dec()
+		metrics.KafkaDeliveries.WithLabelValues(msg.Topic, "success").Inc()
+		ks.deliveries.record(true)
+		keyBytes, _ := msg.Key.Encode()
+		ks.logger.Debug("Message successfully sent to Kafka",
+			zap.String("topic", msg.Topic),
+			zap.Int32("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset),
+			zap.String("key", string(keyBytes)),
+		)
+		notifyDelivery(msg, deliveryResult{report: DeliveryReport{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		}})
+	}
+}
+
 // HealthCheck checks if the Kafka service is healthy
 func (ks *KafkaService) HealthCheck() error {
-	select {
-	case <-ks.ctx.Done():
+	if ks.isClosing() {
 		return fmt.Errorf("kafka service is shutting down")
-	default:
 	}
 
 	// Check if producer is still active
@@ -218,25 +764,147 @@ func (ks *KafkaService) HealthCheck() error {
 	return nil
 }
 
-// Close gracefully shuts down the Kafka service
+// ReadinessCheck verifies broker connectivity, topic metadata and the recent delivery error rate
+func (ks *KafkaService) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	if err := ks.HealthCheck(); err != nil {
+		return []models.HealthCheckResult{{Name: "producer", Status: "fail", Message: err.Error()}}, false
+	}
+
+	results := []models.HealthCheckResult{{Name: "producer", Status: "pass"}}
+	ready := true
+
+	if err := ks.checkMetadata(ctx); err != nil {
+		results = append(results, models.HealthCheckResult{Name: "kafka_metadata", Status: "fail", Message: err.Error()})
+		ready = false
+	} else {
+		results = append(results, models.HealthCheckResult{Name: "kafka_metadata", Status: "pass"})
+	}
+
+	deliveryCheck, deliveriesOK := ks.deliveries.readinessCheck(ks.config.ReadinessMaxErrorRate, ks.config.ReadinessMinSamples)
+	results = append(results, deliveryCheck)
+	ready = ready && deliveriesOK
+
+	return results, ready
+}
+
+// checkMetadata refreshes metadata for every routed topic, reusing recent results
+func (ks *KafkaService) checkMetadata(ctx context.Context) error {
+	ks.metadataMu.Lock()
+	defer ks.metadataMu.Unlock()
+
+	if time.Since(ks.metadataChecked) < metadataCheckTTL {
+		return ks.metadataErr
+	}
+
+	if ks.config.ReadinessTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ks.config.ReadinessTimeout)
+		defer cancel()
+	}
+
+	topics := ks.router.Topics()
+	if ks.DeadLetterEnabled() {
+		topics = append(topics, ks.config.DeadLetterTopic)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ks.verifyTopics(topics)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out fetching Kafka metadata")
+	}
+
+	ks.metadataChecked = time.Now()
+	ks.metadataErr = err
+	return err
+}
+
+// verifyTopics refreshes topic metadata and checks every topic has writable partitions
+func (ks *KafkaService) verifyTopics(topics []string) error {
+	if err := ks.client.RefreshMetadata(topics...); err != nil {
+		return fmt.Errorf("failed to refresh Kafka metadata: %w", err)
+	}
+
+	if len(ks.client.Brokers()) == 0 {
+		return fmt.Errorf("no Kafka brokers available")
+	}
+
+	for _, topic := range topics {
+		partitions, err := ks.client.WritablePartitions(topic)
+		if err != nil {
+			return fmt.Errorf("failed to fetch partitions for topic %s: %w", topic, err)
+		}
+		if len(partitions) == 0 {
+			return fmt.Errorf("topic %s has no writable partitions", topic)
+		}
+	}
+
+	return nil
+}
+
+// Close gracefully shuts down the Kafka service. It stops taking messages, lets the
+// spool replay finish, flushes the producer while the handlers resolve every outcome,
+// and only then cancels the service context.
 func (ks *KafkaService) Close() error {
 	ks.logger.Info("Shutting down Kafka service")
 
-	// Cancel context to stop goroutines
-	ks.cancel()
+	// Refuse new messages, wait for the replay goroutine, then for sends in progress,
+	// so nothing is sent on the producer's input channel once it is closed
+	close(ks.closing)
+	ks.replaying.Wait()
+	ks.inputMu.Lock()
+	ks.inputMu.Unlock()
 
-	// Close producer
+	// The handlers drain the producer until it has flushed and closed its channels, so
+	// waiting callers are answered, the in-flight gauge settles and failed messages are
+	// spooled to be replayed on the next start
 	if ks.producer != nil {
-		if err := ks.producer.Close(); err != nil {
-			ks.logger.Error("Error closing Kafka producer", zap.Error(err))
-			return fmt.Errorf("failed to close Kafka producer: %w", err)
-		}
+		ks.producer.AsyncClose()
+		ks.handlers.Wait()
+	}
+
+	// Cancel context to release anything still waiting on the service
+	ks.cancel()
+
+	ks.closeClient()
+	ks.closeSpool()
+
+	if ks.closeFailures > 0 {
+		ks.logger.Error("Kafka producer failed to deliver messages while closing", zap.Int("messages", ks.closeFailures))
+		return fmt.Errorf("failed to close Kafka producer: %d messages were not delivered", ks.closeFailures)
 	}
 
 	ks.logger.Info("Kafka service shut down successfully")
 	return nil
 }
 
+// closeClient closes the Kafka client shared by the producer
+func (ks *KafkaService) closeClient() {
+	if ks.client == nil || ks.client.Closed() {
+		return
+	}
+
+	if err := ks.client.Close(); err != nil {
+		ks.logger.Error("Error closing Kafka client", zap.Error(err))
+	}
+}
+
+// closeSpool closes the disk spool, if enabled
+func (ks *KafkaService) closeSpool() {
+	if ks.spool == nil {
+		return
+	}
+
+	if err := ks.spool.Close(); err != nil {
+		ks.logger.Error("Error closing spool", zap.Error(err))
+	}
+}
+
 // GetStats returns Kafka producer statistics
 func (ks *KafkaService) GetStats() map[string]interface{} {
 	if ks.producer == nil {
@@ -245,12 +913,31 @@ func (ks *KafkaService) GetStats() map[string]interface{} {
 		}
 	}
 
+	spoolStats := map[string]interface{}{
+		"enabled": ks.spool != nil,
+	}
+	if ks.spool != nil {
+		records, bytes := ks.spool.Depth()
+		spoolStats["records"] = records
+		spoolStats["bytes"] = bytes
+		spoolStats["max_bytes"] = ks.config.SpoolMaxBytes
+	}
+
+	deliveryErrorRate, recentDeliveries := ks.deliveries.errorRate()
+
 	return map[string]interface{}{
-		"status":      "active",
-		"brokers":     ks.config.Brokers,
-		"topic":       ks.config.Topic,
-		"compression": ks.config.Compression,
-		"acks":        ks.config.Acks,
-		"retries":     ks.config.Retries,
+		"status":              "active",
+		"brokers":             ks.config.Brokers,
+		"topic":               ks.config.Topic,
+		"topic_routes":        ks.router.Routes(),
+		"partition_key":       ks.keys.String(),
+		"compression":         ks.config.Compression,
+		"acks":                ks.config.Acks,
+		"retries":             ks.config.Retries,
+		"delivery_timeout_ms": ks.config.DeliveryTimeout.Milliseconds(),
+		"spool":               spoolStats,
+		"delivery_error_rate": deliveryErrorRate,
+		"recent_deliveries":   recentDeliveries,
+		"dead_letter_topic":   ks.config.DeadLetterTopic,
 	}
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"ingestion-service/metrics"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+// newTestKafkaService wires a service to a mock producer, as NewKafkaService does to a real one
+func newTestKafkaService(t *testing.T) (*KafkaService, *mocks.AsyncProducer) {
+	t.Helper()
+
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service := &KafkaService{
+		producer:   producer,
+		deliveries: newDeliveryTracker(0),
+		logger:     zap.NewNop(),
+		ctx:        ctx,
+		cancel:     cancel,
+		closing:    make(chan struct{}),
+	}
+	service.handlers.Add(2)
+	go service.handleErrors()
+	go service.handleSuccesses()
+	return service, producer
+}
+
+func TestKafkaServiceClose(t *testing.T) {
+	service, producer := newTestKafkaService(t)
+	inFlight := testutil.ToFloat64(metrics.KafkaInFlight)
+
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(sarama.ErrBrokerNotAvailable)
+	for i := 0; i < 2; i++ {
+		message := &sarama.ProducerMessage{Topic: "events", Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder("event")}
+		if err := service.enqueue(context.Background(), message); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	// Close waits for the handlers to see both outcomes before it returns
+	service.Close()
+
+	if got := testutil.ToFloat64(metrics.KafkaInFlight); got != inFlight {
+		t.Errorf("in-flight gauge = %v after Close, want %v", got, inFlight)
+	}
+
+	// A publish racing shutdown is refused instead of sending on the closed producer
+	message := &sarama.ProducerMessage{Topic: "events", Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder("late")}
+	if err := service.enqueue(context.Background(), message); err == nil {
+		t.Error("enqueue after Close succeeded, want an error")
+	}
+}
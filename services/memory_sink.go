@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"ingestion-service/models"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// MemorySink keeps published events in memory. It is meant for tests and local
+// development; once maxEvents are held the oldest events are discarded.
+type MemorySink struct {
+	mu          sync.RWMutex
+	events      []models.EnrichedEvent
+	deadLetters []models.DeadLetterEnvelope
+	maxEvents   int
+	published   int64
+	discarded   int64
+	closed      bool
+	logger      *zap.Logger
+}
+
+// NewMemorySink creates a sink holding up to maxEvents events and as many dead letters
+func NewMemorySink(maxEvents int, logger *zap.Logger) *MemorySink {
+	return &MemorySink{
+		maxEvents: maxEvents,
+		logger:    logger,
+	}
+}
+
+// Name returns the sink name
+func (s *MemorySink) Name() string {
+	return SinkMemory
+}
+
+// PublishEvent stores an event
+func (s *MemorySink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	_, err := s.PublishEventSync(ctx, event)
+	return err
+}
+
+// PublishEventSync stores an event and reports its position in the sink.
+// The offset counts every event published since the sink was created.
+func (s *MemorySink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	if err := ctx.Err(); err != nil {
+		return DeliveryReport{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return DeliveryReport{}, fmt.Errorf("memory sink is closed")
+	}
+
+	if len(s.events) >= s.maxEvents {
+		s.events = s.events[1:]
+		s.discarded++
+	}
+	s.events = append(s.events, event)
+	offset := s.published
+	s.published++
+
+	s.logger.Debug("Event stored in memory sink",
+		zap.String("event_id", event.EventID),
+		zap.String("event_type", event.EventType),
+		zap.Int64("offset", offset),
+	)
+
+	return DeliveryReport{Topic: SinkMemory, Offset: offset}, nil
+}
+
+// DeadLetterEnabled reports that dead letters are always kept
+func (s *MemorySink) DeadLetterEnabled() bool {
+	return true
+}
+
+// MaxDeadLetterPayloadBytes returns 0; payloads are kept whole
+func (s *MemorySink) MaxDeadLetterPayloadBytes() int {
+	return 0
+}
+
+// PublishDeadLetter stores a dead-letter envelope
+func (s *MemorySink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("memory sink is closed")
+	}
+
+	if len(s.deadLetters) >= s.maxEvents {
+		s.deadLetters = s.deadLetters[1:]
+	}
+	s.deadLetters = append(s.deadLetters, envelope)
+	return nil
+}
+
+// Events returns a copy of the stored events, oldest first
+func (s *MemorySink) Events() []models.EnrichedEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.EnrichedEvent(nil), s.events...)
+}
+
+// DeadLetters returns a copy of the stored dead-letter envelopes, oldest first
+func (s *MemorySink) DeadLetters() []models.DeadLetterEnvelope {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.DeadLetterEnvelope(nil), s.deadLetters...)
+}
+
+// Reset discards every stored event and dead letter
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+	s.deadLetters = nil
+}
+
+// HealthCheck reports whether the sink is open
+func (s *MemorySink) HealthCheck() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("memory sink is closed")
+	}
+	return nil
+}
+
+// ReadinessCheck reports the sink ready while it is open
+func (s *MemorySink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	if err := s.HealthCheck(); err != nil {
+		return []models.HealthCheckResult{{Name: "memory_sink", Status: "fail", Message: err.Error()}}, false
+	}
+	return []models.HealthCheckResult{{Name: "memory_sink", Status: "pass"}}, true
+}
+
+// GetStats returns the number of events held and published
+func (s *MemorySink) GetStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return map[string]interface{}{
+		"status":       "active",
+		"events":       len(s.events),
+		"dead_letters": len(s.deadLetters),
+		"max_events":   s.maxEvents,
+		"published":    s.published,
+		"discarded":    s.discarded,
+	}
+}
+
+// Close stops the sink from accepting events; stored events remain readable
+func (s *MemorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// NATSConfig holds NATS JetStream sink configuration
+type NATSConfig struct {
+	URL           string
+	Subject       string
+	SubjectRoutes []TopicRoute
+
+	// Stream captures the subjects. With CreateStream it is created or updated on start
+	// with Replicas and Compression; otherwise it must already exist.
+	Stream       string
+	CreateStream bool
+	Replicas     int
+	// Compression is none or s2, applied by the server to stored messages
+	Compression string
+
+	// MaxPending bounds asynchronous publishes awaiting an acknowledgement
+	MaxPending      int
+	DeliveryTimeout time.Duration
+
+	// DeadLetterSubject receives invalid and undeliverable events; disabled when empty
+	DeadLetterSubject string
+
+	// Readiness settings, as for KafkaConfig
+	ReadinessErrorWindow  time.Duration
+	ReadinessMaxErrorRate float64
+	ReadinessMinSamples   int
+	ReadinessTimeout      time.Duration
+}
+
+// NATSSink publishes events to NATS JetStream. Events are routed to subjects like Kafka
+// topics, and JetStream acknowledges each message once the stream has stored it.
+type NATSSink struct {
+	conn       *nats.Conn
+	js         jetstream.JetStream
+	router     *TopicRouter
+	deliveries *deliveryTracker
+	config     NATSConfig
+	logger     *zap.Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	pending sync.WaitGroup
+	// publishing counts publish calls in progress, which Close waits for without holding mu
+	publishing sync.WaitGroup
+
+	streamMu      sync.Mutex
+	streamChecked time.Time
+	streamErr     error
+}
+
+// NewNATSSink connects to NATS and, if configured, creates the stream
+func NewNATSSink(config NATSConfig, logger *zap.Logger) (*NATSSink, error) {
+	router, err := NewTopicRouter(config.SubjectRoutes, config.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize subject routes: %w", err)
+	}
+
+	compression := jetstream.NoCompression
+	switch config.Compression {
+	case "none":
+	case "s2":
+		compression = jetstream.S2Compression
+	default:
+		return nil, fmt.Errorf("invalid compression: %s", config.Compression)
+	}
+
+	conn, err := nats.Connect(config.URL,
+		nats.Name("ingestion-service"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.Warn("Disconnected from NATS", zap.Error(err))
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.Info("Reconnected to NATS", zap.String("url", conn.ConnectedUrlRedacted()))
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn,
+		jetstream.WithPublishAsyncMaxPending(config.MaxPending),
+		jetstream.WithPublishAsyncTimeout(config.DeliveryTimeout),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	sink := &NATSSink{
+		conn:       conn,
+		js:         js,
+		router:     router,
+		deliveries: newDeliveryTracker(config.ReadinessErrorWindow),
+		config:     config,
+		logger:     logger,
+	}
+
+	if config.CreateStream {
+		if err := sink.ensureStream(compression); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	logger.Info("NATS JetStream sink initialized successfully",
+		zap.String("url", conn.ConnectedUrlRedacted()),
+		zap.String("stream", config.Stream),
+		zap.Strings("subjects", sink.subjects()),
+	)
+
+	return sink, nil
+}
+
+// ensureStream creates the stream for every routed subject, or updates an existing one
+func (s *NATSSink) ensureStream(compression jetstream.StoreCompression) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.DeliveryTimeout)
+	defer cancel()
+
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        s.config.Stream,
+		Subjects:    s.subjects(),
+		Storage:     jetstream.FileStorage,
+		Replicas:    s.config.Replicas,
+		Compression: compression,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream stream %s: %w", s.config.Stream, err)
+	}
+	return nil
+}
+
+// subjects returns every routed subject and the dead-letter subject
+func (s *NATSSink) subjects() []string {
+	subjects := s.router.Topics()
+	if s.DeadLetterEnabled() {
+		subjects = append(subjects, s.config.DeadLetterSubject)
+	}
+	return subjects
+}
+
+// Name returns the sink name
+func (s *NATSSink) Name() string {
+	return SinkNATS
+}
+
+// newNATSMsg serializes the value into a message with the given headers
+func newNATSMsg(subject string, value interface{}, headers []messageHeader) (*nats.Msg, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message value: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	for _, header := range headers {
+		msg.Header.Set(header.key, header.value)
+	}
+	return msg, nil
+}
+
+// publishOptions sets the event ID as the JetStream message ID, so the stream drops
+// redeliveries of the same event within its duplicate window
+func publishOptions(event models.EnrichedEvent) []jetstream.PublishOpt {
+	if event.EventID == "" {
+		return nil
+	}
+	return []jetstream.PublishOpt{jetstream.WithMsgID(event.EventID)}
+}
+
+// PublishEvent publishes an event asynchronously to the subject routed for its event type
+func (s *NATSSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	msg, err := newNATSMsg(s.router.Resolve(event.EventType), event, eventHeaders(ctx, event))
+	if err != nil {
+		return err
+	}
+
+	return s.publishAsync(msg, false, publishOptions(event)...)
+}
+
+// PublishEventSync publishes an event and waits until the stream has stored it
+func (s *NATSSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	msg, err := newNATSMsg(s.router.Resolve(event.EventType), event, eventHeaders(ctx, event))
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+
+	if err := s.beginPublish(); err != nil {
+		return DeliveryReport{}, err
+	}
+	defer s.publishing.Done()
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.DeliveryTimeout)
+	defer cancel()
+
+	ack, err := s.js.PublishMsg(ctx, msg, publishOptions(event)...)
+	s.recordDelivery(msg.Subject, err)
+	if err != nil {
+		return DeliveryReport{}, fmt.Errorf("NATS delivery failed: %w", err)
+	}
+
+	return DeliveryReport{Topic: msg.Subject, Offset: int64(ack.Sequence)}, nil
+}
+
+// publishAsync hands a message to the JetStream publisher and resolves its
+// acknowledgement in the background
+func (s *NATSSink) publishAsync(msg *nats.Msg, deadLetter bool, opts ...jetstream.PublishOpt) error {
+	if err := s.beginPublish(); err != nil {
+		return err
+	}
+	defer s.publishing.Done()
+
+	future, err := s.js.PublishMsgAsync(msg, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	s.pending.Add(1)
+	go s.awaitAck(future, deadLetter)
+	return nil
+}
+
+// beginPublish registers a publish call unless the sink is shutting down. The lock is only
+// held for the check, since a publish can block while JetStream is stalled; the caller must
+// call s.publishing.Done when it returns.
+func (s *NATSSink) beginPublish() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("NATS sink is shutting down")
+	}
+	s.publishing.Add(1)
+	return nil
+}
+
+// awaitAck records the outcome of an asynchronous publish. Events were already
+// acknowledged to the client, so failed ones are dead-lettered.
+func (s *NATSSink) awaitAck(future jetstream.PubAckFuture, deadLetter bool) {
+	defer s.pending.Done()
+
+	msg := future.Msg()
+	select {
+	case <-future.Ok():
+		s.recordDelivery(msg.Subject, nil)
+	case err := <-future.Err():
+		s.recordDelivery(msg.Subject, err)
+		s.logger.Error("NATS publish error",
+			zap.String("subject", msg.Subject),
+			zap.String("request_id", msg.Header.Get(HeaderRequestID)),
+			zap.Error(err),
+		)
+		if !deadLetter {
+			s.deadLetterUndelivered(msg, err)
+		}
+	}
+}
+
+// recordDelivery counts a publish outcome for metrics and readiness
+func (s *NATSSink) recordDelivery(subject string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.BrokerDeliveries.WithLabelValues(SinkNATS, subject, result).Inc()
+	s.deliveries.record(err == nil)
+}
+
+// deadLetterUndelivered publishes an event JetStream did not acknowledge to the dead-letter subject
+func (s *NATSSink) deadLetterUndelivered(msg *nats.Msg, cause error) {
+	if !s.DeadLetterEnabled() {
+		return
+	}
+
+	requestID := msg.Header.Get(HeaderRequestID)
+	envelope := models.NewDeadLetterEnvelope(msg.Data, "NATS_DELIVERY_FAILED", cause.Error(), requestID, s.MaxDeadLetterPayloadBytes())
+	envelope.SourceTopic = msg.Subject
+	if err := s.PublishDeadLetter(context.Background(), envelope); err != nil {
+		s.logger.Error("Failed to publish undeliverable message to dead-letter subject",
+			zap.String("subject", msg.Subject),
+			zap.String("request_id", requestID),
+			zap.Error(err),
+		)
+	}
+}
+
+// DeadLetterEnabled reports whether a dead-letter subject is configured
+func (s *NATSSink) DeadLetterEnabled() bool {
+	return s.config.DeadLetterSubject != ""
+}
+
+// MaxDeadLetterPayloadBytes returns the largest raw payload that fits in a dead-letter envelope
+func (s *NATSSink) MaxDeadLetterPayloadBytes() int {
+	return int(s.conn.MaxPayload() / 2)
+}
+
+// PublishDeadLetter publishes an envelope to the dead-letter subject
+func (s *NATSSink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	if !s.DeadLetterEnabled() {
+		return nil
+	}
+
+	msg, err := newNATSMsg(s.config.DeadLetterSubject, envelope, deadLetterHeaders(ctx, envelope))
+	if err != nil {
+		return err
+	}
+
+	return s.publishAsync(msg, true)
+}
+
+// HealthCheck checks if the NATS connection is usable
+func (s *NATSSink) HealthCheck() error {
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+	if closed {
+		return fmt.Errorf("NATS sink is shutting down")
+	}
+
+	if s.conn.IsClosed() {
+		return fmt.Errorf("NATS connection is closed")
+	}
+
+	return nil
+}
+
+// ReadinessCheck verifies the connection, that a stream captures every subject, and the
+// recent delivery error rate
+func (s *NATSSink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	if err := s.HealthCheck(); err != nil {
+		return []models.HealthCheckResult{{Name: "nats_connection", Status: "fail", Message: err.Error()}}, false
+	}
+
+	results := make([]models.HealthCheckResult, 0, 3)
+	ready := true
+
+	if !s.conn.IsConnected() {
+		results = append(results, models.HealthCheckResult{
+			Name:    "nats_connection",
+			Status:  "fail",
+			Message: fmt.Sprintf("connection is %s", s.conn.Status()),
+		})
+		ready = false
+	} else {
+		results = append(results, models.HealthCheckResult{Name: "nats_connection", Status: "pass"})
+	}
+
+	if err := s.checkStreams(ctx); err != nil {
+		results = append(results, models.HealthCheckResult{Name: "nats_streams", Status: "fail", Message: err.Error()})
+		ready = false
+	} else {
+		results = append(results, models.HealthCheckResult{Name: "nats_streams", Status: "pass"})
+	}
+
+	deliveryCheck, deliveriesOK := s.deliveries.readinessCheck(s.config.ReadinessMaxErrorRate, s.config.ReadinessMinSamples)
+	results = append(results, deliveryCheck)
+
+	return results, ready && deliveriesOK
+}
+
+// checkStreams looks up the stream capturing each subject, reusing recent results
+func (s *NATSSink) checkStreams(ctx context.Context) error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	if time.Since(s.streamChecked) < metadataCheckTTL {
+		return s.streamErr
+	}
+
+	if s.config.ReadinessTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.ReadinessTimeout)
+		defer cancel()
+	}
+
+	var err error
+	for _, subject := range s.subjects() {
+		if _, lookupErr := s.js.StreamNameBySubject(ctx, subject); lookupErr != nil {
+			if errors.Is(lookupErr, jetstream.ErrStreamNotFound) {
+				err = fmt.Errorf("no JetStream stream captures subject %s", subject)
+			} else {
+				err = fmt.Errorf("failed to look up stream for subject %s: %w", subject, lookupErr)
+			}
+			break
+		}
+	}
+
+	s.streamChecked = time.Now()
+	s.streamErr = err
+	return err
+}
+
+// GetStats returns NATS publisher statistics
+func (s *NATSSink) GetStats() map[string]interface{} {
+	deliveryErrorRate, recentDeliveries := s.deliveries.errorRate()
+
+	return map[string]interface{}{
+		"status":              "active",
+		"url":                 s.conn.ConnectedUrlRedacted(),
+		"connection":          s.conn.Status().String(),
+		"stream":              s.config.Stream,
+		"subject":             s.config.Subject,
+		"subject_routes":      s.router.Routes(),
+		"compression":         s.config.Compression,
+		"pending_acks":        s.js.PublishAsyncPending(),
+		"delivery_timeout_ms": s.config.DeliveryTimeout.Milliseconds(),
+		"delivery_error_rate": deliveryErrorRate,
+		"recent_deliveries":   recentDeliveries,
+		"dead_letter_subject": s.config.DeadLetterSubject,
+	}
+}
+
+// Close waits for pending acknowledgements, up to the delivery timeout, and closes the connection.
+// Events still unacknowledged then are logged as failed rather than dead-lettered.
+func (s *NATSSink) Close() error {
+	s.logger.Info("Shutting down NATS sink")
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	// Publishes already past the check finish before the acknowledgements are drained
+	s.publishing.Wait()
+
+	select {
+	case <-s.js.PublishAsyncComplete():
+	case <-time.After(s.config.DeliveryTimeout):
+		s.logger.Warn("Timed out waiting for NATS acknowledgements",
+			zap.Int("pending", s.js.PublishAsyncPending()),
+		)
+	}
+
+	// Fail publishes still unacknowledged so their outcome is recorded
+	s.js.CleanupPublisher()
+	s.pending.Wait()
+	s.conn.Close()
+
+	s.logger.Info("NATS sink shut down successfully")
+	return nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ingestion-service/models"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// startNATSServer runs an embedded JetStream server for the test
+func startNATSServer(t *testing.T) string {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("starting NATS server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv.ClientURL()
+}
+
+func newTestNATSSink(t *testing.T, url string) *NATSSink {
+	t.Helper()
+
+	sink, err := NewNATSSink(NATSConfig{
+		URL:                  url,
+		Subject:              "events",
+		SubjectRoutes:        []TopicRoute{{Pattern: "purchase_*", Topic: "purchases"}},
+		Stream:               "EVENTS",
+		CreateStream:         true,
+		Replicas:             1,
+		Compression:          "none",
+		MaxPending:           64,
+		DeliveryTimeout:      5 * time.Second,
+		DeadLetterSubject:    "events-dlq",
+		ReadinessErrorWindow: time.Minute,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNATSSink: %v", err)
+	}
+	return sink
+}
+
+// streamMessages returns the payloads stored in the stream for a subject
+func streamMessages(t *testing.T, url, subject string) []*nats.Msg {
+	t.Helper()
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("connecting to NATS: %v", err)
+	}
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("jetstream.New: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := js.Stream(ctx, "EVENTS")
+	if err != nil {
+		t.Fatalf("looking up stream: %v", err)
+	}
+	info, err := stream.Info(ctx, jetstream.WithSubjectFilter(subject))
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+
+	var msgs []*nats.Msg
+	for seq := uint64(1); seq <= info.State.LastSeq; seq++ {
+		raw, err := stream.GetMsg(ctx, seq)
+		if err != nil || raw.Subject != subject {
+			continue
+		}
+		msgs = append(msgs, &nats.Msg{Subject: raw.Subject, Data: raw.Data, Header: raw.Header})
+	}
+	return msgs
+}
+
+func TestNATSSinkPublish(t *testing.T) {
+	url := startNATSServer(t)
+	sink := newTestNATSSink(t, url)
+
+	report, err := sink.PublishEventSync(context.Background(), models.EnrichedEvent{EventID: "evt-1", EventType: "purchase_completed"})
+	if err != nil {
+		t.Fatalf("PublishEventSync: %v", err)
+	}
+	if report.Topic != "purchases" || report.Offset != 1 {
+		t.Errorf("report = %+v, want subject purchases at sequence 1", report)
+	}
+
+	if err := sink.PublishEvent(context.Background(), models.EnrichedEvent{EventID: "evt-2", EventType: "page_view"}); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+	// A repeated event ID is dropped by the stream's duplicate window
+	if err := sink.PublishEvent(context.Background(), models.EnrichedEvent{EventID: "evt-2", EventType: "page_view"}); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+
+	// Close waits for the asynchronous acknowledgements
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	msgs := streamMessages(t, url, "events")
+	if len(msgs) != 1 {
+		t.Fatalf("stored %d messages on events, want 1", len(msgs))
+	}
+	var event models.EnrichedEvent
+	if err := json.Unmarshal(msgs[0].Data, &event); err != nil || event.EventID != "evt-2" {
+		t.Errorf("stored event = %+v (%v), want evt-2", event, err)
+	}
+	if got := msgs[0].Header.Get(HeaderEventType); got != "page_view" {
+		t.Errorf("%s header = %q, want page_view", HeaderEventType, got)
+	}
+
+	if err := sink.PublishEvent(context.Background(), models.EnrichedEvent{EventType: "page_view"}); err == nil {
+		t.Error("PublishEvent after Close succeeded, want an error")
+	}
+}
+
+func TestNATSSinkDeadLetter(t *testing.T) {
+	url := startNATSServer(t)
+	sink := newTestNATSSink(t, url)
+
+	envelope := models.NewDeadLetterEnvelope([]byte(`{"bad"`), "INVALID_JSON", "unexpected end of JSON input", "req-1", sink.MaxDeadLetterPayloadBytes())
+	if err := sink.PublishDeadLetter(context.Background(), envelope); err != nil {
+		t.Fatalf("PublishDeadLetter: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	msgs := streamMessages(t, url, "events-dlq")
+	if len(msgs) != 1 {
+		t.Fatalf("stored %d dead letters, want 1", len(msgs))
+	}
+	var stored models.DeadLetterEnvelope
+	if err := json.Unmarshal(msgs[0].Data, &stored); err != nil {
+		t.Fatalf("decoding dead letter: %v", err)
+	}
+	if stored.ErrorCode != "INVALID_JSON" || stored.RequestID != "req-1" {
+		t.Errorf("dead letter = %+v, want INVALID_JSON for req-1", stored)
+	}
+}
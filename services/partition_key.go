@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"ingestion-service/models"
+	"strconv"
+	"strings"
+)
+
+// hashKeyPrefix marks a partition key spec that hashes several fields together
+const hashKeyPrefix = "hash:"
+
+// PartitionKeyStrategy derives the Kafka message key for an event.
+// Supported specs are a single field (event_id, user_id, session_id, event_type),
+// a JSON path into event_data such as $.cart.id, or hash:<field>,<field>,...
+// combining any of those. Events that resolve to an empty key fall back to event_id.
+type PartitionKeyStrategy struct {
+	spec   string
+	fields []keyField
+	hash   bool
+}
+
+// keyField resolves one component of a partition key
+type keyField func(event models.EnrichedEvent) string
+
+// NewPartitionKeyStrategy parses a partition key spec
+func NewPartitionKeyStrategy(spec string) (*PartitionKeyStrategy, error) {
+	if spec == "" {
+		spec = "event_id"
+	}
+
+	strategy := &PartitionKeyStrategy{spec: spec}
+	names := []string{spec}
+	if strings.HasPrefix(spec, hashKeyPrefix) {
+		strategy.hash = true
+		names = strings.Split(strings.TrimPrefix(spec, hashKeyPrefix), ",")
+	}
+
+	for _, name := range names {
+		field, err := parseKeyField(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		strategy.fields = append(strategy.fields, field)
+	}
+
+	return strategy, nil
+}
+
+// parseKeyField resolves a field name or JSON path to a key component
+func parseKeyField(name string) (keyField, error) {
+	switch name {
+	case "event_id":
+		return func(event models.EnrichedEvent) string { return event.EventID }, nil
+	case "user_id":
+		return func(event models.EnrichedEvent) string { return event.UserID }, nil
+	case "session_id":
+		return func(event models.EnrichedEvent) string { return event.SessionID }, nil
+	case "event_type":
+		return func(event models.EnrichedEvent) string { return event.EventType }, nil
+	}
+
+	if strings.HasPrefix(name, "$.") {
+		path, err := parseJSONPath(name)
+		if err != nil {
+			return nil, err
+		}
+		return func(event models.EnrichedEvent) string {
+			return lookupJSONPath(event.EventData, path)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported partition key field %q", name)
+}
+
+// Key returns the message key for the event
+func (s *PartitionKeyStrategy) Key(event models.EnrichedEvent) string {
+	if !s.hash {
+		if key := s.fields[0](event); key != "" {
+			return key
+		}
+		return event.EventID
+	}
+
+	hasher := fnv.New64a()
+	empty := true
+	for _, field := range s.fields {
+		value := field(event)
+		if value != "" {
+			empty = false
+		}
+		hasher.Write([]byte(value))
+		hasher.Write([]byte{0})
+	}
+
+	if empty {
+		return event.EventID
+	}
+	return strconv.FormatUint(hasher.Sum64(), 16)
+}
+
+// String returns the spec the strategy was built from
+func (s *PartitionKeyStrategy) String() string {
+	return s.spec
+}
+
+// jsonPathSegment is one step of a JSON path: an object key or an array index
+type jsonPathSegment struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses a dotted JSON path such as $.cart.items[0].sku
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	var segments []jsonPathSegment
+	for _, part := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		key := part
+		var indexes []int
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			for rest := part[open:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if !strings.HasPrefix(rest, "[") || end < 0 {
+					return nil, fmt.Errorf("invalid partition key path %q", path)
+				}
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid array index in partition key path %q", path)
+				}
+				indexes = append(indexes, index)
+				rest = rest[end+1:]
+			}
+		}
+
+		if key == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("invalid partition key path %q", path)
+		}
+		if key != "" {
+			segments = append(segments, jsonPathSegment{key: key, index: -1})
+		}
+		for _, index := range indexes {
+			segments = append(segments, jsonPathSegment{index: index})
+		}
+	}
+	return segments, nil
+}
+
+// lookupJSONPath walks event_data along the path and renders the value as a string
+func lookupJSONPath(data map[string]interface{}, path []jsonPathSegment) string {
+	var current interface{} = data
+	for _, segment := range path {
+		if segment.key != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			current = object[segment.key]
+			continue
+		}
+
+		array, ok := current.([]interface{})
+		if !ok || segment.index >= len(array) {
+			return ""
+		}
+		current = array[segment.index]
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
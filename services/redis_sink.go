@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// redisPayloadField is the stream entry field holding the JSON message; the others are headers
+const redisPayloadField = "payload"
+
+// RedisConfig holds Redis Streams sink configuration
+type RedisConfig struct {
+	URL          string
+	Stream       string
+	StreamRoutes []TopicRoute
+
+	// MaxLen trims each stream to about this many entries; 0 keeps every entry
+	MaxLen int64
+	// MinReplicas is how many replicas must acknowledge a write, through WAIT, before it
+	// counts as delivered; 0 only waits for the primary
+	MinReplicas int
+	// Retries is how often go-redis re-sends a pipeline after a network error. XADD is not
+	// idempotent, so entries written before the error are appended again with new IDs.
+	Retries int
+
+	// BatchSize entries are pipelined per round trip, waiting at most LingerMs for a batch to fill
+	BatchSize       int
+	LingerMs        int
+	QueueSize       int
+	MaxMessageBytes int
+	DeliveryTimeout time.Duration
+
+	// DeadLetterStream receives invalid and undeliverable events; disabled when empty
+	DeadLetterStream string
+
+	// Readiness settings, as for KafkaConfig
+	ReadinessErrorWindow  time.Duration
+	ReadinessMaxErrorRate float64
+	ReadinessMinSamples   int
+	ReadinessTimeout      time.Duration
+}
+
+// RedisSink appends events to Redis streams. Events are routed to streams like Kafka
+// topics and written in pipelined batches by a background writer.
+type RedisSink struct {
+	client     *redis.Client
+	router     *TopicRouter
+	queue      chan *redisEntry
+	deliveries *deliveryTracker
+	config     RedisConfig
+	logger     *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	// stop is closed when Close starts, releasing publishers blocked on a full queue
+	stop chan struct{}
+	// publishing counts enqueue calls in progress; the queue is closed once they return
+	publishing sync.WaitGroup
+
+	pingMu      sync.Mutex
+	pingChecked time.Time
+	pingErr     error
+}
+
+// redisEntry is a stream entry waiting to be written
+type redisEntry struct {
+	stream     string
+	values     []string
+	deadLetter bool
+	// result receives the outcome when the publisher waits for it
+	result chan deliveryResult
+}
+
+// NewRedisSink connects to Redis and starts the writer
+func NewRedisSink(config RedisConfig, logger *zap.Logger) (*RedisSink, error) {
+	router, err := NewTopicRouter(config.StreamRoutes, config.Stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize stream routes: %w", err)
+	}
+
+	options, err := redis.ParseURL(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	options.MaxRetries = config.Retries
+	if config.Retries == 0 {
+		// go-redis treats 0 as its default of 3
+		options.MaxRetries = -1
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DeliveryTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	sink := &RedisSink{
+		client:     client,
+		router:     router,
+		queue:      make(chan *redisEntry, config.QueueSize),
+		deliveries: newDeliveryTracker(config.ReadinessErrorWindow),
+		config:     config,
+		logger:     logger,
+		done:       make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	go sink.run()
+
+	logger.Info("Redis Streams sink initialized successfully",
+		zap.String("addr", options.Addr),
+		zap.Int("db", options.DB),
+		zap.Strings("streams", router.Topics()),
+	)
+
+	return sink, nil
+}
+
+// Name returns the sink name
+func (s *RedisSink) Name() string {
+	return SinkRedis
+}
+
+// newEntry serializes the value into a stream entry with the given headers
+func (s *RedisSink) newEntry(stream string, value interface{}, headers []messageHeader) (*redisEntry, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message value: %w", err)
+	}
+	if len(data) > s.config.MaxMessageBytes {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", len(data), s.config.MaxMessageBytes)
+	}
+
+	values := make([]string, 0, 2+2*len(headers))
+	values = append(values, redisPayloadField, string(data))
+	for _, header := range headers {
+		values = append(values, header.key, header.value)
+	}
+	return &redisEntry{stream: stream, values: values}, nil
+}
+
+// PublishEvent queues an event for the stream routed for its event type
+func (s *RedisSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	entry, err := s.newEntry(s.router.Resolve(event.EventType), event, eventHeaders(ctx, event))
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(ctx, entry)
+}
+
+// PublishEventSync publishes an event and waits until Redis, and MinReplicas replicas, have stored it
+func (s *RedisSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	entry, err := s.newEntry(s.router.Resolve(event.EventType), event, eventHeaders(ctx, event))
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+	entry.result = make(chan deliveryResult, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.DeliveryTimeout)
+	defer cancel()
+
+	if err := s.enqueue(ctx, entry); err != nil {
+		return DeliveryReport{}, err
+	}
+
+	select {
+	case result := <-entry.result:
+		return result.report, result.err
+	case <-ctx.Done():
+		return DeliveryReport{}, fmt.Errorf("timed out waiting for Redis delivery confirmation: %w", ctx.Err())
+	}
+}
+
+// enqueue hands an entry to the writer. The lock is only held to register the call, since
+// the send blocks while the queue is full.
+func (s *RedisSink) enqueue(ctx context.Context, entry *redisEntry) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return fmt.Errorf("redis sink is shutting down")
+	}
+	s.publishing.Add(1)
+	s.mu.RUnlock()
+	defer s.publishing.Done()
+
+	select {
+	case s.queue <- entry:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled while sending message")
+	case <-s.stop:
+		return fmt.Errorf("redis sink is shutting down")
+	}
+}
+
+// run writes queued entries in batches until the queue is closed
+func (s *RedisSink) run() {
+	defer close(s.done)
+
+	linger := time.Duration(s.config.LingerMs) * time.Millisecond
+	batch := make([]*redisEntry, 0, s.config.BatchSize)
+
+	for entry := range s.queue {
+		batch = append(batch[:0], entry)
+
+		timer := time.NewTimer(linger)
+	fill:
+		for len(batch) < s.config.BatchSize {
+			select {
+			case entry, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, entry)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+
+		s.write(batch)
+	}
+}
+
+// write appends a batch in one pipeline and resolves each entry's outcome
+func (s *RedisSink) write(batch []*redisEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.DeliveryTimeout)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(batch))
+	for i, entry := range batch {
+		cmds[i] = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: entry.stream,
+			MaxLen: s.config.MaxLen,
+			Approx: s.config.MaxLen > 0,
+			Values: entry.values,
+		})
+	}
+
+	// WAIT covers every write made earlier on the pipeline's connection. Half the
+	// delivery timeout is left for the writes themselves.
+	var wait *redis.Cmd
+	if s.config.MinReplicas > 0 {
+		wait = pipe.Do(ctx, "WAIT", s.config.MinReplicas, (s.config.DeliveryTimeout / 2).Milliseconds())
+	}
+
+	// Errors are reported per command
+	pipe.Exec(ctx)
+
+	var replicaErr error
+	if wait != nil {
+		replicas, err := wait.Int64()
+		if err != nil {
+			replicaErr = fmt.Errorf("failed to wait for Redis replicas: %w", err)
+		} else if replicas < int64(s.config.MinReplicas) {
+			replicaErr = fmt.Errorf("only %d of %d Redis replicas acknowledged the write", replicas, s.config.MinReplicas)
+		}
+	}
+
+	var deadLetters []*redisEntry
+	for i, entry := range batch {
+		id, err := cmds[i].Result()
+		if err == nil {
+			err = replicaErr
+		}
+		if deadLetter := s.complete(entry, id, err); deadLetter != nil {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+
+	// Dead letters are never dead-lettered themselves, so this recurses at most once
+	if len(deadLetters) > 0 {
+		s.write(deadLetters)
+	}
+}
+
+// complete records an entry's outcome and reports it to a waiting publisher. Events
+// nobody waits for were already acknowledged to the client, so for failed ones it
+// returns a dead-letter entry to write.
+func (s *RedisSink) complete(entry *redisEntry, id string, err error) *redisEntry {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.BrokerDeliveries.WithLabelValues(SinkRedis, entry.stream, result).Inc()
+	s.deliveries.record(err == nil)
+
+	if err != nil {
+		s.logger.Error("Redis write error",
+			zap.String("stream", entry.stream),
+			zap.String("request_id", entry.header(HeaderRequestID)),
+			zap.Error(err),
+		)
+	}
+
+	if entry.result != nil {
+		if err != nil {
+			err = fmt.Errorf("redis delivery failed: %w", err)
+		}
+		entry.result <- deliveryResult{report: DeliveryReport{Topic: entry.stream, ID: id}, err: err}
+		return nil
+	}
+
+	if err != nil && !entry.deadLetter {
+		return s.deadLetterEntry(entry, err)
+	}
+	return nil
+}
+
+// header returns a header value of the entry
+func (e *redisEntry) header(key string) string {
+	for i := 2; i+1 < len(e.values); i += 2 {
+		if e.values[i] == key {
+			return e.values[i+1]
+		}
+	}
+	return ""
+}
+
+// deadLetterEntry builds the dead-letter stream entry for an entry Redis did not store
+func (s *RedisSink) deadLetterEntry(entry *redisEntry, cause error) *redisEntry {
+	if !s.DeadLetterEnabled() {
+		return nil
+	}
+
+	requestID := entry.header(HeaderRequestID)
+	envelope := models.NewDeadLetterEnvelope([]byte(entry.values[1]), "REDIS_DELIVERY_FAILED", cause.Error(), requestID, s.MaxDeadLetterPayloadBytes())
+	envelope.SourceTopic = entry.stream
+
+	deadLetter, err := s.newEntry(s.config.DeadLetterStream, envelope, deadLetterHeaders(context.Background(), envelope))
+	if err != nil {
+		s.logger.Error("Failed to build dead-letter entry for undeliverable message",
+			zap.String("stream", entry.stream),
+			zap.String("request_id", requestID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	deadLetter.deadLetter = true
+	return deadLetter
+}
+
+// DeadLetterEnabled reports whether a dead-letter stream is configured
+func (s *RedisSink) DeadLetterEnabled() bool {
+	return s.config.DeadLetterStream != ""
+}
+
+// MaxDeadLetterPayloadBytes returns the largest raw payload that fits in a dead-letter envelope
+func (s *RedisSink) MaxDeadLetterPayloadBytes() int {
+	return s.config.MaxMessageBytes / 2
+}
+
+// PublishDeadLetter queues an envelope for the dead-letter stream
+func (s *RedisSink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	if !s.DeadLetterEnabled() {
+		return nil
+	}
+
+	entry, err := s.newEntry(s.config.DeadLetterStream, envelope, deadLetterHeaders(ctx, envelope))
+	if err != nil {
+		return err
+	}
+	entry.deadLetter = true
+
+	return s.enqueue(ctx, entry)
+}
+
+// HealthCheck checks if the Redis sink is accepting events
+func (s *RedisSink) HealthCheck() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("redis sink is shutting down")
+	}
+
+	return nil
+}
+
+// ReadinessCheck pings Redis and checks the recent delivery error rate
+func (s *RedisSink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	if err := s.HealthCheck(); err != nil {
+		return []models.HealthCheckResult{{Name: "writer", Status: "fail", Message: err.Error()}}, false
+	}
+
+	results := []models.HealthCheckResult{{Name: "writer", Status: "pass"}}
+	ready := true
+
+	if err := s.ping(ctx); err != nil {
+		results = append(results, models.HealthCheckResult{Name: "redis_ping", Status: "fail", Message: err.Error()})
+		ready = false
+	} else {
+		results = append(results, models.HealthCheckResult{Name: "redis_ping", Status: "pass"})
+	}
+
+	deliveryCheck, deliveriesOK := s.deliveries.readinessCheck(s.config.ReadinessMaxErrorRate, s.config.ReadinessMinSamples)
+	results = append(results, deliveryCheck)
+
+	return results, ready && deliveriesOK
+}
+
+// ping checks Redis responds, reusing recent results
+func (s *RedisSink) ping(ctx context.Context) error {
+	s.pingMu.Lock()
+	defer s.pingMu.Unlock()
+
+	if time.Since(s.pingChecked) < metadataCheckTTL {
+		return s.pingErr
+	}
+
+	if s.config.ReadinessTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.ReadinessTimeout)
+		defer cancel()
+	}
+
+	err := s.client.Ping(ctx).Err()
+	if err != nil {
+		err = fmt.Errorf("failed to ping Redis: %w", err)
+	}
+
+	s.pingChecked = time.Now()
+	s.pingErr = err
+	return err
+}
+
+// GetStats returns Redis writer statistics
+func (s *RedisSink) GetStats() map[string]interface{} {
+	deliveryErrorRate, recentDeliveries := s.deliveries.errorRate()
+	pool := s.client.PoolStats()
+
+	return map[string]interface{}{
+		"status":              "active",
+		"addr":                s.client.Options().Addr,
+		"stream":              s.config.Stream,
+		"stream_routes":       s.router.Routes(),
+		"max_len":             s.config.MaxLen,
+		"min_replicas":        s.config.MinReplicas,
+		"queued":              len(s.queue),
+		"connections":         pool.TotalConns,
+		"delivery_timeout_ms": s.config.DeliveryTimeout.Milliseconds(),
+		"delivery_error_rate": deliveryErrorRate,
+		"recent_deliveries":   recentDeliveries,
+		"dead_letter_stream":  s.config.DeadLetterStream,
+	}
+}
+
+// Close stops accepting events, writes what is queued and closes the client
+func (s *RedisSink) Close() error {
+	s.logger.Info("Shutting down Redis sink")
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	// No send can be in progress once the publishers return, so the queue can be closed
+	s.publishing.Wait()
+	close(s.queue)
+	<-s.done
+
+	if err := s.client.Close(); err != nil {
+		s.logger.Error("Error closing Redis client", zap.Error(err))
+		return fmt.Errorf("failed to close Redis client: %w", err)
+	}
+
+	s.logger.Info("Redis sink shut down successfully")
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"ingestion-service/models"
+)
+
+// EventSink is a destination that accepted events are published to
+type EventSink interface {
+	// Name identifies the sink in logs and stats
+	Name() string
+
+	// PublishEvent hands an event to the sink without waiting for it to be stored
+	PublishEvent(ctx context.Context, event models.EnrichedEvent) error
+	// PublishEventSync publishes an event and waits until the sink has stored it
+	PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error)
+
+	// DeadLetterEnabled reports whether the sink keeps rejected payloads
+	DeadLetterEnabled() bool
+	// MaxDeadLetterPayloadBytes returns the largest raw payload kept in a dead-letter envelope; 0 means no limit
+	MaxDeadLetterPayloadBytes() int
+	// PublishDeadLetter stores an envelope for a payload that could not be ingested
+	PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error
+
+	// HealthCheck reports whether the sink can accept events
+	HealthCheck() error
+	// ReadinessCheck runs the sink's readiness checks and reports whether all passed
+	ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool)
+	// GetStats returns sink statistics
+	GetStats() map[string]interface{}
+
+	// Close flushes pending events and releases the sink's resources
+	Close() error
+}
+
+// Compile-time checks that the sinks implement EventSink
+var (
+	_ EventSink = (*KafkaService)(nil)
+	_ EventSink = (*MemorySink)(nil)
+	_ EventSink = (*FileSink)(nil)
+	_ EventSink = (*FanoutSink)(nil)
+	_ EventSink = (*WebhookSink)(nil)
+	_ EventSink = (*NATSSink)(nil)
+	_ EventSink = (*RedisSink)(nil)
+)
+
+// Sink types selectable with SINK_TYPE
+const (
+	SinkKafka   = "kafka"
+	SinkMemory  = "memory"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+	SinkNATS    = "nats"
+	SinkRedis   = "redis"
+)
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ingestion-service/metrics"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// spoolSegmentPrefix and spoolSegmentSuffix name the segment files of the spool
+	spoolSegmentPrefix = "spool-"
+	spoolSegmentSuffix = ".log"
+
+	// spoolCorruptSuffix is appended to a segment's name for the file holding its corrupt records
+	spoolCorruptSuffix = ".corrupt"
+
+	// defaultSpoolSegmentBytes is the size at which the active segment is sealed
+	defaultSpoolSegmentBytes = 64 * 1024 * 1024
+
+	// maxSpoolRecordBytes bounds a single line when reading segments back
+	maxSpoolRecordBytes = 16 * 1024 * 1024
+)
+
+// ErrSpoolFull is returned when appending would exceed the configured spool size
+var ErrSpoolFull = errors.New("spool is full")
+
+// SpoolConfig holds disk spool configuration
+type SpoolConfig struct {
+	Dir          string
+	MaxBytes     int64
+	SegmentBytes int64
+}
+
+// Spool is an append-only, segmented write-ahead log of messages awaiting redelivery
+type Spool struct {
+	config SpoolConfig
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	segments   []*spoolSegment
+	active     *os.File
+	nextSeq    uint64
+	totalBytes int64
+	records    int64
+}
+
+// spoolSegment describes a single segment file on disk
+type spoolSegment struct {
+	seq     uint64
+	path    string
+	bytes   int64
+	records int64
+}
+
+// NewSpool opens the spool directory and indexes any segments left from a previous run
+func NewSpool(config SpoolConfig, logger *zap.Logger) (*Spool, error) {
+	if config.SegmentBytes <= 0 {
+		config.SegmentBytes = defaultSpoolSegmentBytes
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	spool := &Spool{
+		config: config,
+		logger: logger,
+	}
+
+	if err := spool.loadSegments(); err != nil {
+		return nil, err
+	}
+
+	if spool.records > 0 {
+		logger.Warn("Recovered undelivered messages from spool",
+			zap.String("dir", config.Dir),
+			zap.Int64("records", spool.records),
+			zap.Int64("bytes", spool.totalBytes),
+		)
+	}
+
+	return spool, nil
+}
+
+// loadSegments scans the spool directory for existing segment files
+func (s *Spool) loadSegments() error {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, spoolSegmentPrefix) || !strings.HasSuffix(name, spoolSegmentSuffix) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, spoolSegmentPrefix), spoolSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		segment := &spoolSegment{seq: seq, path: filepath.Join(s.config.Dir, name)}
+		if err := segment.count(); err != nil {
+			return err
+		}
+
+		s.segments = append(s.segments, segment)
+		s.totalBytes += segment.bytes
+		s.records += segment.records
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	return nil
+}
+
+// count reads a segment to determine its record count and size
+func (seg *spoolSegment) count() error {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpoolRecordBytes)
+	for scanner.Scan() {
+		seg.records++
+		seg.bytes += int64(len(scanner.Bytes()) + 1)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read spool segment %s: %w", seg.path, err)
+	}
+	return nil
+}
+
+// Append durably writes a message to the active segment
+func (s *Spool) Append(message Message) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MaxBytes > 0 && s.totalBytes+int64(len(line)) > s.config.MaxBytes {
+		return ErrSpoolFull
+	}
+
+	if s.active == nil {
+		if err := s.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.active.Write(line); err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+
+	segment := s.segments[len(s.segments)-1]
+	segment.bytes += int64(len(line))
+	segment.records++
+	s.totalBytes += int64(len(line))
+	s.records++
+
+	// Seal the segment once it reaches its size limit
+	if segment.bytes >= s.config.SegmentBytes {
+		s.sealActive()
+	}
+
+	return nil
+}
+
+// openSegment creates a new active segment; the caller must hold the lock
+func (s *Spool) openSegment() error {
+	segment := &spoolSegment{
+		seq:  s.nextSeq,
+		path: filepath.Join(s.config.Dir, fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, s.nextSeq, spoolSegmentSuffix)),
+	}
+
+	file, err := os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+
+	s.nextSeq++
+	s.active = file
+	s.segments = append(s.segments, segment)
+	return nil
+}
+
+// sealActive closes the active segment so it can be replayed; the caller must hold the lock
+func (s *Spool) sealActive() {
+	if s.active == nil {
+		return
+	}
+
+	if err := s.active.Close(); err != nil {
+		s.logger.Error("Failed to close spool segment", zap.Error(err))
+	}
+	s.active = nil
+}
+
+// Depth returns the number of spooled records and their size in bytes
+func (s *Spool) Depth() (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records, s.totalBytes
+}
+
+// Replay drains the spool oldest-first, handing records to deliver in chunks of batchSize.
+// deliver returns one error per record; a nil entry means the record no longer needs the
+// spool, either because it was delivered or because the caller dead-lettered or dropped it.
+// Failed records stay on disk together with everything after them, and Replay returns the
+// first failure so the caller can retry later.
+func (s *Spool) Replay(batchSize int, deliver func([]Message) []error) (int, error) {
+	replayed := 0
+
+	for {
+		segment := s.oldestSegment()
+		if segment == nil {
+			return replayed, nil
+		}
+
+		messages, corrupt, err := readSegment(segment.path)
+		if err != nil {
+			return replayed, err
+		}
+		if len(corrupt) > 0 {
+			if err := s.quarantine(segment, corrupt, messages); err != nil {
+				return replayed, err
+			}
+		}
+
+		for len(messages) > 0 {
+			n := batchSize
+			if n > len(messages) {
+				n = len(messages)
+			}
+
+			var failed []Message
+			var firstErr error
+			for i, err := range deliver(messages[:n]) {
+				if err == nil {
+					continue
+				}
+				failed = append(failed, messages[i])
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+			replayed += n - len(failed)
+
+			if firstErr != nil {
+				// Persist only what is left so delivered records are not replayed again
+				if rewriteErr := s.rewriteSegment(segment, append(failed, messages[n:]...)); rewriteErr != nil {
+					s.logger.Error("Failed to rewrite spool segment", zap.Error(rewriteErr))
+				}
+				return replayed, firstErr
+			}
+
+			messages = messages[n:]
+		}
+
+		s.removeSegment(segment)
+	}
+}
+
+// oldestSegment returns the oldest segment, sealing the active one if it is the only one left
+func (s *Spool) oldestSegment() *spoolSegment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		return nil
+	}
+
+	if len(s.segments) == 1 && s.active != nil {
+		if s.segments[0].records == 0 {
+			return nil
+		}
+		s.sealActive()
+	}
+
+	return s.segments[0]
+}
+
+// readSegment loads every record from a sealed segment. Only the last line may fail to decode,
+// since a crash can tear the final write; any earlier undecodable line is corruption and is
+// returned separately so it can be moved aside.
+func readSegment(path string) ([]Message, [][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	var messages []Message
+	var corrupt [][]byte
+	var torn []byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpoolRecordBytes)
+	for scanner.Scan() {
+		if torn != nil {
+			// The bad line was not the last one after all
+			corrupt = append(corrupt, torn)
+			torn = nil
+		}
+
+		var message Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			torn = append([]byte(nil), scanner.Bytes()...)
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read spool segment %s: %w", path, err)
+	}
+	return messages, corrupt, nil
+}
+
+// quarantine appends corrupt lines to a file next to the segment, where replay no longer picks
+// them up, and rewrites the segment with the records that decoded
+func (s *Spool) quarantine(segment *spoolSegment, corrupt [][]byte, valid []Message) error {
+	path := segment.path + spoolCorruptSuffix
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool quarantine file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, line := range corrupt {
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write spool quarantine file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync spool quarantine file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close spool quarantine file: %w", err)
+	}
+
+	metrics.SpoolRecords.WithLabelValues("corrupt").Add(float64(len(corrupt)))
+	s.logger.Error("Moved corrupt spool records aside",
+		zap.String("segment", segment.path),
+		zap.String("quarantine", path),
+		zap.Int("records", len(corrupt)),
+	)
+
+	return s.rewriteSegment(segment, valid)
+}
+
+// rewriteSegment replaces a segment with the records that have not been delivered yet
+func (s *Spool) rewriteSegment(segment *spoolSegment, remaining []Message) error {
+	tmpPath := segment.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+
+	var size int64
+	writer := bufio.NewWriter(file)
+	for _, message := range remaining {
+		line, err := json.Marshal(message)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal spool record: %w", err)
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+		size += int64(len(line) + 1)
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+	if err := os.Rename(tmpPath, segment.path); err != nil {
+		return fmt.Errorf("failed to replace spool segment: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totalBytes -= segment.bytes - size
+	s.records -= segment.records - int64(len(remaining))
+	segment.bytes = size
+	segment.records = int64(len(remaining))
+	return nil
+}
+
+// removeSegment deletes a fully replayed segment
+func (s *Spool) removeSegment(segment *spoolSegment) {
+	if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("Failed to remove spool segment", zap.String("path", segment.path), zap.Error(err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, seg := range s.segments {
+		if seg == segment {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			break
+		}
+	}
+	s.totalBytes -= segment.bytes
+	s.records -= segment.records
+}
+
+// Close closes the active segment
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
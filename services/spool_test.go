@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestSpool(t *testing.T, dir string) *Spool {
+	t.Helper()
+	spool, err := NewSpool(SpoolConfig{Dir: dir}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	return spool
+}
+
+func spoolTopics(messages []Message) []string {
+	topics := make([]string, len(messages))
+	for i, message := range messages {
+		topics[i] = message.Topic
+	}
+	return topics
+}
+
+func TestSpoolReplay(t *testing.T) {
+	errBroker := errors.New("broker unavailable")
+
+	tests := []struct {
+		name      string
+		records   []string
+		batchSize int
+		// fail returns the error deliver reports for a record
+		fail         func(topic string) error
+		wantReplayed int
+		wantErr      bool
+		wantLeft     []string
+	}{
+		{
+			name:         "all delivered",
+			records:      []string{"a", "b", "c"},
+			batchSize:    2,
+			fail:         func(string) error { return nil },
+			wantReplayed: 3,
+		},
+		{
+			name:         "failed record kept with the rest of the segment",
+			records:      []string{"a", "b", "c", "d", "e"},
+			batchSize:    2,
+			fail:         func(topic string) error { return map[string]error{"c": errBroker}[topic] },
+			wantReplayed: 3,
+			wantErr:      true,
+			wantLeft:     []string{"c", "e"},
+		},
+		{
+			name:         "delivered records in a failed batch are not kept",
+			records:      []string{"a", "b", "c"},
+			batchSize:    3,
+			fail:         func(topic string) error { return map[string]error{"a": errBroker}[topic] },
+			wantReplayed: 2,
+			wantErr:      true,
+			wantLeft:     []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			spool := newTestSpool(t, dir)
+			for _, topic := range tt.records {
+				if err := spool.Append(Message{Topic: topic, Value: []byte(`{}`)}); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+
+			replayed, err := spool.Replay(tt.batchSize, func(messages []Message) []error {
+				errs := make([]error, len(messages))
+				for i, message := range messages {
+					errs[i] = tt.fail(message.Topic)
+				}
+				return errs
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Replay error = %v, want error %v", err, tt.wantErr)
+			}
+			if replayed != tt.wantReplayed {
+				t.Errorf("replayed = %d, want %d", replayed, tt.wantReplayed)
+			}
+
+			records, _ := spool.Depth()
+			if records != int64(len(tt.wantLeft)) {
+				t.Errorf("depth = %d, want %d", records, len(tt.wantLeft))
+			}
+
+			// A fresh spool sees only what was left on disk
+			var left []Message
+			if _, err := newTestSpool(t, dir).Replay(10, func(messages []Message) []error {
+				left = append(left, messages...)
+				return make([]error, len(messages))
+			}); err != nil {
+				t.Fatalf("second Replay: %v", err)
+			}
+			if got := strings.Join(spoolTopics(left), ","); got != strings.Join(tt.wantLeft, ",") {
+				t.Errorf("left on disk = %q, want %q", got, strings.Join(tt.wantLeft, ","))
+			}
+		})
+	}
+}
+
+func TestSpoolReplayCorruptRecords(t *testing.T) {
+	dir := t.TempDir()
+	segment := filepath.Join(dir, "spool-00000000000000000000.log")
+	lines := `{"topic":"a","value":"e30="}` + "\n" +
+		`not json` + "\n" +
+		`{"topic":"b","value":"e30="}` + "\n" +
+		`{"topic":"c","val`
+	if err := os.WriteFile(segment, []byte(lines), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var delivered []Message
+	replayed, err := newTestSpool(t, dir).Replay(10, func(messages []Message) []error {
+		delivered = append(delivered, messages...)
+		return make([]error, len(messages))
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed != 2 {
+		t.Errorf("replayed = %d, want 2", replayed)
+	}
+	if got := strings.Join(spoolTopics(delivered), ","); got != "a,b" {
+		t.Errorf("delivered = %q, want %q", got, "a,b")
+	}
+
+	// The torn final line is skipped; only the line in the middle is corruption
+	quarantined, err := os.ReadFile(segment + spoolCorruptSuffix)
+	if err != nil {
+		t.Fatalf("reading quarantine file: %v", err)
+	}
+	if string(quarantined) != "not json\n" {
+		t.Errorf("quarantined = %q, want %q", quarantined, "not json\n")
+	}
+	if _, err := os.Stat(segment); !os.IsNotExist(err) {
+		t.Errorf("segment still exists after replay: %v", err)
+	}
+}
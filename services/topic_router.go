@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexRoutePrefix marks a route pattern as a regular expression
+const regexRoutePrefix = "re:"
+
+// TopicRoute maps an event type pattern to a destination topic
+type TopicRoute struct {
+	Pattern string `json:"pattern"`
+	Topic   string `json:"topic"`
+}
+
+// TopicRouter resolves the destination topic for an event type.
+// Patterns are exact event types, globs such as purchase_*, or regular
+// expressions prefixed with "re:". The first matching route wins.
+type TopicRouter struct {
+	rules        []topicRule
+	defaultTopic string
+}
+
+// topicRule is a compiled topic route
+type topicRule struct {
+	pattern string
+	topic   string
+	match   func(eventType string) bool
+}
+
+// NewTopicRouter compiles the routes and falls back to defaultTopic when none match
+func NewTopicRouter(routes []TopicRoute, defaultTopic string) (*TopicRouter, error) {
+	router := &TopicRouter{defaultTopic: defaultTopic}
+
+	for _, route := range routes {
+		if route.Pattern == "" || route.Topic == "" {
+			return nil, fmt.Errorf("topic route must have a pattern and a topic")
+		}
+
+		match, err := compileEventTypePattern(route.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic route: %w", err)
+		}
+
+		rule := topicRule{pattern: route.Pattern, topic: route.Topic, match: match}
+		router.rules = append(router.rules, rule)
+	}
+
+	return router, nil
+}
+
+// compileEventTypePattern compiles an exact event type, a glob such as purchase_*, or a
+// regular expression prefixed with "re:" into a matcher
+func compileEventTypePattern(pattern string) (func(eventType string) bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, regexRoutePrefix):
+		expr, err := regexp.Compile(strings.TrimPrefix(pattern, regexRoutePrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		return expr.MatchString, nil
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		return func(eventType string) bool {
+			matched, _ := path.Match(pattern, eventType)
+			return matched
+		}, nil
+	default:
+		return func(eventType string) bool {
+			return eventType == pattern
+		}, nil
+	}
+}
+
+// Resolve returns the topic for the given event type
+func (r *TopicRouter) Resolve(eventType string) string {
+	for _, rule := range r.rules {
+		if rule.match(eventType) {
+			return rule.topic
+		}
+	}
+	return r.defaultTopic
+}
+
+// Topics returns every topic the router can resolve to, default first
+func (r *TopicRouter) Topics() []string {
+	topics := []string{r.defaultTopic}
+	seen := map[string]bool{r.defaultTopic: true}
+	for _, rule := range r.rules {
+		if !seen[rule.topic] {
+			seen[rule.topic] = true
+			topics = append(topics, rule.topic)
+		}
+	}
+	return topics
+}
+
+// Routes returns the configured routes in evaluation order
+func (r *TopicRouter) Routes() []TopicRoute {
+	routes := make([]TopicRoute, len(r.rules))
+	for i, rule := range r.rules {
+		routes[i] = TopicRoute{Pattern: rule.pattern, Topic: rule.topic}
+	}
+	return routes
+}
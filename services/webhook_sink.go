@@ -0,0 +1,633 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ingestion-service/metrics"
+	"ingestion-service/models"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Webhook request headers
+const (
+	WebhookHeaderID        = "X-Webhook-ID"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+	WebhookHeaderBatchSize = "X-Webhook-Batch-Size"
+)
+
+var (
+	// ErrCircuitOpen is returned for an endpoint whose circuit breaker is open
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrWebhookQueueFull is returned when an endpoint's queue stays full until the publisher gives up
+	ErrWebhookQueueFull = errors.New("webhook queue is full")
+)
+
+// WebhookEndpoint is a destination of the webhook sink
+type WebhookEndpoint struct {
+	Name string
+	URL  string
+	// Secret keys the HMAC-SHA256 request signature; empty sends unsigned requests
+	Secret string
+	// EventTypes are exact event types, globs or re: regular expressions; empty selects every event
+	EventTypes []string
+}
+
+// WebhookSinkConfig holds webhook sink configuration
+type WebhookSinkConfig struct {
+	Endpoints []WebhookEndpoint
+
+	// Timeout bounds a single request
+	Timeout time.Duration
+	// MaxRetries is how many times a failed request is retried, with exponential
+	// backoff from BackoffInitial up to BackoffMax
+	MaxRetries     int
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// Concurrency limits requests in flight across all endpoints, and batches outstanding per endpoint
+	Concurrency int
+	// BatchSize events are sent per request, or fewer after BatchInterval; 1 sends events singly
+	BatchSize     int
+	BatchInterval time.Duration
+	// QueueSize bounds the events waiting per endpoint; publishers wait while it is full
+	QueueSize int
+
+	// BreakerThreshold consecutive failed requests open an endpoint's circuit for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// WebhookSink POSTs events to HTTP endpoints. Events are queued per endpoint and sent
+// in batches by background workers, so batches to one endpoint may arrive out of order.
+type WebhookSink struct {
+	config    WebhookSinkConfig
+	endpoints []*webhookEndpoint
+	client    *http.Client
+	slots     chan struct{}
+	logger    *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	deliveries sync.WaitGroup
+	dispatch   sync.WaitGroup
+}
+
+// webhookEndpoint is an endpoint with its queue, breaker and counters
+type webhookEndpoint struct {
+	WebhookEndpoint
+	eventTypes []func(eventType string) bool
+	queue      chan webhookItem
+	breaker    *circuitBreaker
+	// inflight holds a slot per batch being delivered, so the dispatcher stops draining the
+	// queue while Concurrency batches are outstanding and a full queue pushes back on publishers
+	inflight chan struct{}
+
+	mu        sync.Mutex
+	delivered int64
+	failed    int64
+	lastErr   error
+}
+
+// webhookItem is a queued event; done receives the delivery outcome when the publisher waits for it
+type webhookItem struct {
+	event models.EnrichedEvent
+	done  chan error
+}
+
+// webhookStatusError is a non-2xx response
+type webhookStatusError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// Error describes the response status
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("endpoint responded with status %d", e.status)
+}
+
+// NewWebhookSink creates the sink and starts a dispatcher per endpoint
+func NewWebhookSink(config WebhookSinkConfig, logger *zap.Logger) (*WebhookSink, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("at least one webhook endpoint must be configured")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := &WebhookSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		slots:  make(chan struct{}, config.Concurrency),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, endpoint := range config.Endpoints {
+		if _, err := url.ParseRequestURI(endpoint.URL); err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid URL for webhook endpoint %s: %w", endpoint.Name, err)
+		}
+
+		compiled := &webhookEndpoint{
+			WebhookEndpoint: endpoint,
+			queue:           make(chan webhookItem, config.QueueSize),
+			inflight:        make(chan struct{}, config.Concurrency),
+			breaker:         newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown),
+		}
+		for _, pattern := range endpoint.EventTypes {
+			match, err := compileEventTypePattern(pattern)
+			if err != nil {
+				cancel()
+				return nil, fmt.Errorf("invalid event type filter for webhook endpoint %s: %w", endpoint.Name, err)
+			}
+			compiled.eventTypes = append(compiled.eventTypes, match)
+		}
+		sink.endpoints = append(sink.endpoints, compiled)
+		metrics.WebhookCircuitState.WithLabelValues(endpoint.Name).Set(0)
+	}
+
+	for _, endpoint := range sink.endpoints {
+		sink.dispatch.Add(1)
+		go sink.run(endpoint)
+	}
+
+	return sink, nil
+}
+
+// Name returns the sink name
+func (s *WebhookSink) Name() string {
+	return SinkWebhook
+}
+
+// PublishEvent queues the event for every endpoint selecting it, waiting while a queue is
+// full. It fails for endpoints whose circuit is open or whose queue stays full until ctx is
+// done; once another endpoint has queued the event, the error wraps ErrRetriesExhausted.
+func (s *WebhookSink) PublishEvent(ctx context.Context, event models.EnrichedEvent) error {
+	_, err := s.enqueue(ctx, event, false)
+	return err
+}
+
+// PublishEventSync queues the event and waits until every endpoint that queued it has
+// acknowledged it, so a failure is only reported once the other endpoints' outcomes are
+// known. The report names the first endpoint.
+func (s *WebhookSink) PublishEventSync(ctx context.Context, event models.EnrichedEvent) (DeliveryReport, error) {
+	pending, err := s.enqueue(ctx, event, true)
+
+	var report DeliveryReport
+	var failures []error
+	if err != nil {
+		failures = append(failures, err)
+	}
+	for i, p := range pending {
+		select {
+		case err := <-p.done:
+			if err != nil {
+				failures = append(failures, fmt.Errorf("webhook %s: %w", p.endpoint, err))
+			} else if i == 0 {
+				report.Topic = p.endpoint
+			}
+		case <-ctx.Done():
+			return DeliveryReport{}, ctx.Err()
+		}
+	}
+
+	if len(failures) > 0 {
+		return DeliveryReport{}, errors.Join(failures...)
+	}
+	return report, nil
+}
+
+// pendingDelivery is an event queued for an endpoint that the publisher waits on
+type pendingDelivery struct {
+	endpoint string
+	done     chan error
+}
+
+// enqueue hands the event to the queue of every endpoint selecting it, waiting for room
+// until ctx is done or the sink closes
+func (s *WebhookSink) enqueue(ctx context.Context, event models.EnrichedEvent, wait bool) ([]pendingDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("webhook sink is closed")
+	}
+
+	var pending []pendingDelivery
+	var failures []error
+	queued := 0
+	for _, endpoint := range s.endpoints {
+		if !endpoint.accepts(event.EventType) {
+			continue
+		}
+		if endpoint.breaker.isOpen() {
+			metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "circuit_open").Inc()
+			failures = append(failures, fmt.Errorf("webhook %s: %w", endpoint.Name, ErrCircuitOpen))
+			continue
+		}
+
+		item := webhookItem{event: event}
+		if wait {
+			item.done = make(chan error, 1)
+		}
+
+		if err := s.push(ctx, endpoint, item); err != nil {
+			failures = append(failures, fmt.Errorf("webhook %s: %w", endpoint.Name, err))
+			continue
+		}
+		queued++
+		if wait {
+			pending = append(pending, pendingDelivery{endpoint: endpoint.Name, done: item.done})
+		}
+	}
+
+	err := errors.Join(failures...)
+	if err != nil && queued > 0 {
+		// Retrying the publish would send the event again to the endpoints that queued it
+		return pending, fmt.Errorf("%w: %w", ErrRetriesExhausted, err)
+	}
+	return pending, err
+}
+
+// push adds the item to the endpoint's queue, waiting while it is full. The caller holds
+// the read lock, so Close cancels s.ctx before taking the write lock to release waiters.
+func (s *WebhookSink) push(ctx context.Context, endpoint *webhookEndpoint, item webhookItem) error {
+	select {
+	case endpoint.queue <- item:
+		return nil
+	default:
+	}
+
+	select {
+	case endpoint.queue <- item:
+		return nil
+	case <-ctx.Done():
+		metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "queue_full").Inc()
+		return fmt.Errorf("%w: %w", ErrWebhookQueueFull, ctx.Err())
+	case <-s.ctx.Done():
+		metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "queue_full").Inc()
+		return fmt.Errorf("%w: webhook sink is closing", ErrWebhookQueueFull)
+	}
+}
+
+// accepts reports whether the endpoint's filter selects the event type
+func (e *webhookEndpoint) accepts(eventType string) bool {
+	if len(e.eventTypes) == 0 {
+		return true
+	}
+	for _, match := range e.eventTypes {
+		if match(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// run collects the endpoint's queued events into batches until the queue is closed.
+// A batch is sent when full, after BatchInterval, or at once when a publisher waits on it.
+// Sending waits for one of the endpoint's in-flight slots, so at most Concurrency batches
+// per endpoint are held outside the queue.
+func (s *WebhookSink) run(endpoint *webhookEndpoint) {
+	defer s.dispatch.Done()
+
+	timer := time.NewTimer(s.config.BatchInterval)
+	timer.Stop()
+
+	var batch []webhookItem
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
+			return
+		}
+		items := batch
+		batch = nil
+
+		endpoint.inflight <- struct{}{}
+		s.deliveries.Add(1)
+		go func() {
+			defer s.deliveries.Done()
+			defer func() { <-endpoint.inflight }()
+			s.deliver(endpoint, items)
+		}()
+	}
+
+	for {
+		select {
+		case item, ok := <-endpoint.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) == 1 {
+				timer.Reset(s.config.BatchInterval)
+			}
+			if len(batch) >= s.config.BatchSize || item.done != nil {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// deliver sends a batch, retrying transient failures, and reports the outcome to waiting publishers
+func (s *WebhookSink) deliver(endpoint *webhookEndpoint, items []webhookItem) {
+	err := s.send(endpoint, items)
+
+	endpoint.mu.Lock()
+	if err == nil {
+		endpoint.delivered += int64(len(items))
+	} else {
+		endpoint.failed += int64(len(items))
+	}
+	endpoint.lastErr = err
+	endpoint.mu.Unlock()
+
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "success").Add(float64(len(items)))
+	} else {
+		metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "failure").Add(float64(len(items)))
+		s.logger.Error("Webhook delivery failed",
+			zap.String("endpoint", endpoint.Name),
+			zap.Int("events", len(items)),
+			zap.Error(err),
+		)
+	}
+
+	for _, item := range items {
+		if item.done != nil {
+			item.done <- err
+		}
+	}
+}
+
+// send POSTs the batch until it succeeds, fails permanently, runs out of retries or the
+// circuit opens. Retries stop once the sink is shutting down.
+func (s *WebhookSink) send(endpoint *webhookEndpoint, items []webhookItem) error {
+	body, err := webhookBody(items)
+	if err != nil {
+		return err
+	}
+	deliveryID := uuid.New().String()
+
+	for attempt := 0; ; attempt++ {
+		for !endpoint.breaker.allow() {
+			if endpoint.breaker.isOpen() {
+				return ErrCircuitOpen
+			}
+
+			// Another batch is probing the half-open circuit; wait for its outcome
+			select {
+			case <-time.After(s.backoff(0, nil)):
+			case <-s.ctx.Done():
+				return fmt.Errorf("shutting down while the circuit is half-open: %w", ErrCircuitOpen)
+			}
+		}
+
+		s.slots <- struct{}{}
+		err = s.post(endpoint, deliveryID, body, len(items))
+		<-s.slots
+
+		state := endpoint.breaker.record(err == nil)
+		metrics.WebhookCircuitState.WithLabelValues(endpoint.Name).Set(float64(state))
+		if err == nil {
+			return nil
+		}
+
+		if !retryable(err) || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		metrics.WebhookDeliveries.WithLabelValues(endpoint.Name, "retry").Add(float64(len(items)))
+		s.logger.Warn("Webhook request failed, retrying",
+			zap.String("endpoint", endpoint.Name),
+			zap.String("delivery_id", deliveryID),
+			zap.Int("attempt", attempt+1),
+			zap.Error(err),
+		)
+
+		select {
+		case <-time.After(s.backoff(attempt, err)):
+		case <-s.ctx.Done():
+			return fmt.Errorf("shutting down after %d attempts: %w", attempt+1, err)
+		}
+	}
+}
+
+// post makes a single signed request
+func (s *WebhookSink) post(endpoint *webhookEndpoint, deliveryID string, body []byte, events int) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ingestion-service-webhook/1.0")
+	req.Header.Set(WebhookHeaderID, deliveryID)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderBatchSize, strconv.Itoa(events))
+	if endpoint.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, "sha256="+signWebhook(endpoint.Secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	statusErr := &webhookStatusError{status: resp.StatusCode}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
+
+// backoff returns the wait before the next attempt: exponential with jitter, capped at
+// BackoffMax, or the endpoint's Retry-After when it asks for longer
+func (s *WebhookSink) backoff(attempt int, err error) time.Duration {
+	delay := s.config.BackoffInitial << attempt
+	if delay <= 0 || delay > s.config.BackoffMax {
+		delay = s.config.BackoffMax
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > delay {
+		delay = min(statusErr.retryAfter, s.config.BackoffMax)
+	}
+	return delay
+}
+
+// retryable reports whether a failed request may succeed when repeated
+func retryable(err error) bool {
+	var statusErr *webhookStatusError
+	if !errors.As(err, &statusErr) {
+		// Network errors and timeouts
+		return true
+	}
+	return statusErr.status == http.StatusRequestTimeout ||
+		statusErr.status == http.StatusTooManyRequests ||
+		statusErr.status >= 500
+}
+
+// webhookBody encodes a single event as an object and a batch as an array
+func webhookBody(items []webhookItem) ([]byte, error) {
+	if len(items) == 1 {
+		return json.Marshal(items[0].event)
+	}
+
+	events := make([]models.EnrichedEvent, len(items))
+	for i, item := range items {
+		events[i] = item.event
+	}
+	return json.Marshal(events)
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body". Including the timestamp
+// lets receivers reject replayed requests.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeadLetterEnabled reports that dead letters are not forwarded to webhooks
+func (s *WebhookSink) DeadLetterEnabled() bool {
+	return false
+}
+
+// MaxDeadLetterPayloadBytes returns 0, as dead letters are not forwarded
+func (s *WebhookSink) MaxDeadLetterPayloadBytes() int {
+	return 0
+}
+
+// PublishDeadLetter ignores the envelope
+func (s *WebhookSink) PublishDeadLetter(ctx context.Context, envelope models.DeadLetterEnvelope) error {
+	return nil
+}
+
+// HealthCheck fails once the sink is closed. Open circuits are a remote problem, so they
+// are reported by ReadinessCheck and GetStats instead.
+func (s *WebhookSink) HealthCheck() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("webhook sink is closed")
+	}
+	return nil
+}
+
+// ReadinessCheck reports each endpoint's circuit state
+func (s *WebhookSink) ReadinessCheck(ctx context.Context) ([]models.HealthCheckResult, bool) {
+	results := make([]models.HealthCheckResult, 0, len(s.endpoints))
+	ready := true
+
+	for _, endpoint := range s.endpoints {
+		check := models.HealthCheckResult{Name: "webhook_" + endpoint.Name, Status: "pass"}
+		if endpoint.breaker.isOpen() {
+			check.Status = "fail"
+			check.Message = ErrCircuitOpen.Error()
+			ready = false
+		}
+		results = append(results, check)
+	}
+
+	return results, ready
+}
+
+// GetStats returns per-endpoint statistics. URLs are reported without query strings,
+// which may carry credentials.
+func (s *WebhookSink) GetStats() map[string]interface{} {
+	endpoints := make(map[string]interface{}, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		endpoint.mu.Lock()
+		stats := map[string]interface{}{
+			"url":       redactURL(endpoint.URL),
+			"circuit":   endpoint.breaker.String(),
+			"queued":    len(endpoint.queue),
+			"delivered": endpoint.delivered,
+			"failed":    endpoint.failed,
+			"signed":    endpoint.Secret != "",
+		}
+		if endpoint.lastErr != nil {
+			stats["last_error"] = endpoint.lastErr.Error()
+		}
+		if len(endpoint.EventTypes) > 0 {
+			stats["event_types"] = endpoint.EventTypes
+		}
+		endpoint.mu.Unlock()
+		endpoints[endpoint.Name] = stats
+	}
+
+	return map[string]interface{}{
+		"status":      "active",
+		"endpoints":   endpoints,
+		"batch_size":  s.config.BatchSize,
+		"concurrency": s.config.Concurrency,
+		"max_retries": s.config.MaxRetries,
+	}
+}
+
+// Close stops accepting events, sends what is queued and waits for requests in flight.
+// Failed requests are not retried during shutdown, and publishers waiting on a full queue
+// give up.
+func (s *WebhookSink) Close() error {
+	s.logger.Info("Shutting down webhook sink")
+
+	s.cancel()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, endpoint := range s.endpoints {
+		close(endpoint.queue)
+	}
+	s.mu.Unlock()
+
+	s.dispatch.Wait()
+	s.deliveries.Wait()
+	return nil
+}
+
+// redactURL drops the query string and user info from a URL
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	parsed.RawQuery = ""
+	parsed.User = nil
+	return parsed.String()
+}
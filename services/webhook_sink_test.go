@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"ingestion-service/models"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// countingServer counts the requests it receives, holding each until release is closed
+func countingServer(t *testing.T, release chan struct{}) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if release != nil {
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestWebhookSink(t *testing.T, queueSize int, endpoints ...WebhookEndpoint) *WebhookSink {
+	t.Helper()
+
+	sink, err := NewWebhookSink(WebhookSinkConfig{
+		Endpoints:        endpoints,
+		Timeout:          5 * time.Second,
+		BackoffInitial:   10 * time.Millisecond,
+		BackoffMax:       10 * time.Millisecond,
+		Concurrency:      1,
+		BatchSize:        1,
+		BatchInterval:    time.Second,
+		QueueSize:        queueSize,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWebhookSink: %v", err)
+	}
+	return sink
+}
+
+func TestWebhookSinkPartialFailure(t *testing.T) {
+	healthy, healthyRequests := countingServer(t, nil)
+	broken, brokenRequests := countingServer(t, nil)
+
+	sink := newTestWebhookSink(t, 10,
+		WebhookEndpoint{Name: "healthy", URL: healthy.URL},
+		WebhookEndpoint{Name: "broken", URL: broken.URL},
+	)
+	// A single failure opens the broken endpoint's circuit for the rest of the test
+	sink.endpoints[1].breaker.record(false)
+
+	event := models.EnrichedEvent{EventID: "evt-1", EventType: "page_view"}
+	err := sink.PublishEvent(context.Background(), event)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("PublishEvent error = %v, want ErrCircuitOpen wrapped in ErrRetriesExhausted", err)
+	}
+
+	_, err = sink.PublishEventSync(context.Background(), event)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("PublishEventSync error = %v, want ErrCircuitOpen", err)
+	}
+	// The delivery-confirmed publish reports only after the healthy endpoint has the event
+	if got := healthyRequests.Load(); got != 2 {
+		t.Errorf("healthy endpoint received %d requests after PublishEventSync, want 2", got)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := brokenRequests.Load(); got != 0 {
+		t.Errorf("broken endpoint received %d requests, want 0", got)
+	}
+	if got := healthyRequests.Load(); got != 2 {
+		t.Errorf("healthy endpoint received %d requests, want 2", got)
+	}
+}
+
+func TestWebhookSinkFullQueueWaits(t *testing.T) {
+	release := make(chan struct{})
+	server, requests := countingServer(t, release)
+	sink := newTestWebhookSink(t, 1, WebhookEndpoint{Name: "slow", URL: server.URL})
+
+	// The first event is in flight, the second waits for the in-flight slot and the third fills the queue
+	for i := 0; i < 3; i++ {
+		if err := sink.PublishEvent(context.Background(), models.EnrichedEvent{EventType: "page_view"}); err != nil {
+			t.Fatalf("PublishEvent %d: %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := sink.PublishEvent(ctx, models.EnrichedEvent{EventType: "page_view"})
+	if !errors.Is(err, ErrWebhookQueueFull) || errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("PublishEvent error = %v, want ErrWebhookQueueFull alone", err)
+	}
+
+	close(release)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("endpoint received %d requests, want 3", got)
+	}
+}